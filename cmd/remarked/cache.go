@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// renderCache keeps the rendered output of pages together with the state of
+// all the files they were generated from. As long as none of these files
+// changes, the cached output is served (or a 304 if the client already has
+// it). Every configuration gets its own cache (see buildMux), so pages
+// rendered with a previous configuration are never served.
+type renderCache struct {
+	Log *logrus.Logger
+
+	// If Security uses nonces, every response gets its own nonce, see
+	// Serve.
+	Security *security
+
+	// Since is the time the configuration was loaded. Pages are never
+	// reported as older than that, so clients don't keep a copy rendered
+	// with a previous configuration.
+	Since time.Time
+
+	// If Disabled is set, pages are rendered for every request, e.g.
+	// because they show the current time.
+	Disabled bool
+
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+	pending map[string]*pendingRender
+	hits    int
+	misses  int
+}
+
+// pendingRender is a render in progress that all callers asking for the
+// same key wait for instead of rendering the page (and running its code
+// snippets) again.
+type pendingRender struct {
+	done  chan struct{}
+	entry *cacheEntry
+	err   error
+}
+
+type cacheEntry struct {
+	body    []byte
+	etag    string
+	modTime time.Time
+	inputs  map[string]inputState
+}
+
+type inputState struct {
+	modTime time.Time
+	size    int64
+}
+
+// renderFunc renders a page and returns its content together with the paths
+// of all files that were used for it.
+type renderFunc func() ([]byte, []string, error)
+
+// Serve responds with the cached page for the given key and only calls
+// render if there is no cached version or one of its input files has
+// changed. Conditional requests are answered based on a strong ETag and the
+// modification time of the newest input file.
+//
+// If the Content-Security-Policy uses nonces, the nonce placeholder inside
+// the page is replaced by a new nonce for every response and the nonce is
+// added to the ETag. A client that revalidates its copy gets a 304 with the
+// policy for the nonce of that copy. The modification time is left out in
+// this case as it doesn't identify the nonce.
+func (c *renderCache) Serve(w http.ResponseWriter, r *http.Request, key string, render renderFunc) error {
+	entry, err := c.get(key, render)
+	if err != nil {
+		return err
+	}
+	if !c.Security.nonces() {
+		w.Header().Set("ETag", entry.etag)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		http.ServeContent(w, r, "", entry.modTime, bytes.NewReader(entry.body))
+		return nil
+	}
+	if nonce, ok := matchNonceETag(r.Header.Get("If-None-Match"), entry.etag); ok && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		c.Security.setHeaders(w.Header(), nonce)
+		w.Header().Set("ETag", nonceETag(entry.etag, nonce))
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	nonce := newNonce()
+	c.Security.setHeaders(w.Header(), nonce)
+	w.Header().Set("ETag", nonceETag(entry.etag, nonce))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	body := bytes.Replace(entry.body, []byte(noncePlaceholder), []byte(nonce), -1)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+	return nil
+}
+
+// Warm renders the page for the given key unless the cached version is still
+// up to date, so that the next request doesn't have to wait for it.
+func (c *renderCache) Warm(key string, render renderFunc) error {
+	_, err := c.get(key, render)
+	return err
+}
+
+func (c *renderCache) get(key string, render renderFunc) (*cacheEntry, error) {
+	if c.Disabled {
+		c.count(key, false)
+		entry, err := newCacheEntry(render, c.Since)
+		if entry != nil {
+			entry.modTime = time.Time{}
+		}
+		return entry, err
+	}
+	c.lock.Lock()
+	entry, found := c.entries[key]
+	c.lock.Unlock()
+	if found && !entry.changed() {
+		c.count(key, true)
+		return entry, nil
+	}
+	c.lock.Lock()
+	if p, found := c.pending[key]; found {
+		c.lock.Unlock()
+		<-p.done
+		return p.entry, p.err
+	}
+	p := &pendingRender{done: make(chan struct{})}
+	if c.pending == nil {
+		c.pending = make(map[string]*pendingRender)
+	}
+	c.pending[key] = p
+	c.lock.Unlock()
+
+	c.count(key, false)
+	p.entry, p.err = newCacheEntry(render, c.Since)
+	c.lock.Lock()
+	if p.err == nil {
+		if c.entries == nil {
+			c.entries = make(map[string]*cacheEntry)
+		}
+		c.entries[key] = p.entry
+	}
+	delete(c.pending, key)
+	c.lock.Unlock()
+	close(p.done)
+	return p.entry, p.err
+}
+
+// newCacheEntry renders the page. Its modification time is the one of the
+// newest input file but not before since.
+func newCacheEntry(render renderFunc, since time.Time) (*cacheEntry, error) {
+	body, inputs, err := render()
+	if err != nil {
+		return nil, err
+	}
+	entry := &cacheEntry{
+		body:    body,
+		etag:    fmt.Sprintf(`"%x"`, sha256.Sum256(body)),
+		modTime: since,
+		inputs:  make(map[string]inputState),
+	}
+	for _, path := range inputs {
+		state := statInput(path)
+		entry.inputs[path] = state
+		if state.modTime.After(entry.modTime) {
+			entry.modTime = state.modTime
+		}
+	}
+	return entry, nil
+}
+
+func (c *renderCache) count(key string, hit bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result := "miss"
+	if hit {
+		c.hits++
+		result = "hit"
+	} else {
+		c.misses++
+	}
+	if c.Log != nil {
+		c.Log.Debugf("Render cache %s for %s (hits: %d, misses: %d)", result, key, c.hits, c.misses)
+	}
+}
+
+func (e *cacheEntry) changed() bool {
+	for path, state := range e.inputs {
+		if statInput(path) != state {
+			return true
+		}
+	}
+	return false
+}
+
+func statInput(path string) inputState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return inputState{size: -1}
+	}
+	return inputState{modTime: info.ModTime(), size: info.Size()}
+}
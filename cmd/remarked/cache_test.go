@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "slides.md")
+	require.NoError(t, ioutil.WriteFile(input, []byte("a"), 0644))
+
+	renders := 0
+	render := func() ([]byte, []string, error) {
+		renders++
+		data, err := ioutil.ReadFile(input)
+		return data, []string{input}, err
+	}
+	cache := renderCache{}
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, cache.Serve(rec, httptest.NewRequest("GET", "/", nil), "page", render))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "a", rec.Body.String())
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	require.NoError(t, cache.Serve(rec, req, "page", render))
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	require.Equal(t, 1, renders, "Unchanged inputs should not trigger a new render")
+
+	require.NoError(t, ioutil.WriteFile(input, []byte("b"), 0644))
+	require.NoError(t, os.Chtimes(input, time.Now(), time.Now().Add(time.Minute)))
+	rec = httptest.NewRecorder()
+	require.NoError(t, cache.Serve(rec, req, "page", render))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "b", rec.Body.String())
+	require.NotEqual(t, etag, rec.Header().Get("ETag"))
+	require.Equal(t, 2, renders)
+
+	// A new configuration makes older copies stale even if the input files
+	// are older.
+	lastModified := rec.Header().Get("Last-Modified")
+	cache = renderCache{Since: time.Now().Add(time.Hour)}
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	rec = httptest.NewRecorder()
+	require.NoError(t, cache.Serve(rec, req, "page", render))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEqual(t, lastModified, rec.Header().Get("Last-Modified"))
+
+	cache = renderCache{Disabled: true}
+	for i := 0; i < 2; i++ {
+		rec = httptest.NewRecorder()
+		require.NoError(t, cache.Serve(rec, httptest.NewRequest("GET", "/", nil), "page", render))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Empty(t, rec.Header().Get("Last-Modified"))
+	}
+	require.Equal(t, 5, renders)
+}
+
+func TestRenderCacheWarm(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var renders int32
+	render := func() ([]byte, []string, error) {
+		if atomic.AddInt32(&renders, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return []byte("a"), nil, nil
+	}
+	cache := renderCache{}
+
+	// Requests arriving while the page is rendered wait for that render
+	// instead of starting another one.
+	warmed := make(chan error)
+	go func() {
+		warmed <- cache.Warm("page", render)
+	}()
+	<-started
+	served := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		require.NoError(t, cache.Serve(rec, httptest.NewRequest("GET", "/", nil), "page", render))
+		served <- rec
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	require.NoError(t, <-warmed)
+	rec := <-served
+	require.Equal(t, "a", rec.Body.String())
+	require.Equal(t, int32(1), atomic.LoadInt32(&renders))
+}
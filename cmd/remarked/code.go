@@ -0,0 +1,397 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// codeMarkerPattern matches region markers. They have to be at the start of
+// a comment so that code like log.Print("START server") isn't mistaken for
+// one.
+var codeMarkerPattern = regexp.MustCompile(`(?:^|\s)(?://|#|--|/\*|<!--|;)\s*(START|END) (\S+)`)
+var backtickPattern = regexp.MustCompile("`+")
+
+const (
+	// codeModeVerbatim includes the code as it is. This should only be used
+	// for trusted HTML.
+	codeModeVerbatim = "verbatim"
+
+	// codeModeEscape escapes all HTML special characters so that the code
+	// shows up as text.
+	codeModeEscape = "escape"
+
+	// codeModeFence wraps the code into a fenced code block which is
+	// escaped by remark.js itself.
+	codeModeFence = "fence"
+)
+
+// codeOptions describe which lines of a file are included by loadCode.
+type codeOptions struct {
+	lines    map[int]struct{}
+	regions  []string
+	from     *regexp.Regexp
+	to       *regexp.Regexp
+	collapse bool
+	mode     string
+	lang     string
+}
+
+func (o *codeOptions) selective() bool {
+	return o.lines != nil || len(o.regions) > 0 || o.from != nil || o.to != nil
+}
+
+// parseCodeOptions parses the options passed to loadCode:
+//
+//	lines=RANGES  includes the given lines (e.g. 10-25,30)
+//	region=NAME   includes the lines between the comments "START NAME" and
+//	              "END NAME"
+//	from=REGEX    includes everything starting at the first matching line
+//	to=REGEX      includes everything up to the next matching line
+//	collapse      replaces omitted lines with a "// ..." comment
+//	mode=MODE     verbatim (default), escape or fence
+//	lang=LANG     language hint for fenced code (implies mode=fence)
+func parseCodeOptions(options []string) (*codeOptions, error) {
+	opts := &codeOptions{}
+	for _, option := range options {
+		elems := strings.SplitN(option, "=", 2)
+		key := elems[0]
+		value := ""
+		if len(elems) == 2 {
+			value = elems[1]
+		}
+		var err error
+		switch key {
+		case "lines":
+			lines, errs := parseLineRangesWithErrors(value)
+			if len(errs) > 0 {
+				return nil, errs[0]
+			}
+			if opts.lines == nil {
+				opts.lines = make(map[int]struct{})
+			}
+			for line := range lines {
+				opts.lines[line] = struct{}{}
+			}
+		case "region":
+			opts.regions = append(opts.regions, value)
+		case "from":
+			opts.from, err = regexp.Compile(value)
+		case "to":
+			opts.to, err = regexp.Compile(value)
+		case "collapse":
+			opts.collapse = true
+		case "mode":
+			switch value {
+			case codeModeVerbatim, codeModeEscape, codeModeFence:
+				opts.mode = value
+			default:
+				return nil, fmt.Errorf("unknown mode %s", value)
+			}
+		case "lang":
+			opts.lang = value
+		default:
+			return nil, fmt.Errorf("unknown option %s", option)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern in %s: %s", option, err.Error())
+		}
+	}
+	if opts.mode == "" {
+		opts.mode = codeModeVerbatim
+		if opts.lang != "" {
+			opts.mode = codeModeFence
+		}
+	}
+	return opts, nil
+}
+
+// LoadCode includes the file at the given path. By default the whole file is
+// included verbatim but the options described in parseCodeOptions can be
+// used to only include parts of it or change how it is embedded. Selected
+// code is dedented and markLines will refer to the line numbers of the
+// original file.
+func (f *templateFuncs) LoadCode(path string, options ...string) (template.HTML, error) {
+	opts, err := parseCodeOptions(options)
+	if err != nil {
+		return "", fmt.Errorf("loadCode %s: %s", path, err.Error())
+	}
+	path = f.resolvePath(path)
+	f.addFile(path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if f.Lenient && os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if !opts.selective() && !opts.collapse && opts.mode == codeModeVerbatim {
+		return template.HTML(data), nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	numbers := make([]int, len(lines))
+	for idx := range numbers {
+		numbers[idx] = idx + 1
+	}
+	if opts.selective() || opts.collapse {
+		lines, numbers, err = selectCode(lines, opts, collapseComment(path))
+		if err != nil {
+			return "", fmt.Errorf("loadCode %s: %s", path, err.Error())
+		}
+	}
+	switch opts.mode {
+	case codeModeEscape:
+		for idx, line := range lines {
+			lines[idx] = template.HTMLEscapeString(line)
+		}
+	case codeModeFence:
+		lang := opts.lang
+		if lang == "" {
+			lang = codeLanguage(path)
+		}
+		fence := codeFence(lines)
+		lines = append(append([]string{fence + lang}, lines...), fence)
+		numbers = append(append([]int{0}, numbers...), 0)
+	}
+	content := strings.Join(lines, "\n")
+	// The whole file keeps its final newline in every mode just like in
+	// verbatim mode.
+	if !opts.selective() && strings.HasSuffix(string(data), "\n") {
+		content += "\n"
+	}
+	f.registerCodeLines(content, numbers)
+	return template.HTML(content), nil
+}
+
+// codeFence returns a fence that is longer than any sequence of backticks
+// inside the given lines.
+func codeFence(lines []string) string {
+	length := 3
+	for _, line := range lines {
+		for _, run := range backtickPattern.FindAllString(line, -1) {
+			if len(run) >= length {
+				length = len(run) + 1
+			}
+		}
+	}
+	return strings.Repeat("`", length)
+}
+
+// codeLanguage returns the language hint for fenced code blocks based on the
+// extension of the given file.
+func codeLanguage(path string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	switch ext {
+	case "js", "mjs":
+		return "javascript"
+	case "ts":
+		return "typescript"
+	case "py":
+		return "python"
+	case "rb":
+		return "ruby"
+	case "sh":
+		return "bash"
+	case "yml":
+		return "yaml"
+	case "rs":
+		return "rust"
+	case "md":
+		return "markdown"
+	case "h":
+		return "c"
+	case "hpp", "cc", "cxx":
+		return "cpp"
+	case "kt":
+		return "kotlin"
+	case "htm":
+		return "html"
+	default:
+		return ext
+	}
+}
+
+// selectCode returns the dedented lines selected by the given options
+// together with their original line numbers. Inserted lines have the number
+// 0. The marker comments of the selected regions are never included. Lines
+// that only consist of a marker are dropped completely while code in front
+// of a marker belongs to the region.
+func selectCode(lines []string, opts *codeOptions, comment string) ([]string, []int, error) {
+	selected := make([]bool, len(lines))
+	// markers maps the index of every line with a marker to the offset the
+	// first marker comment starts at.
+	markers := make(map[int]int)
+	if !opts.selective() {
+		for idx := range selected {
+			selected[idx] = true
+		}
+	}
+	for line := range opts.lines {
+		if line > len(lines) {
+			return nil, nil, fmt.Errorf("line %d is beyond the end of the file", line)
+		}
+		selected[line-1] = true
+	}
+	for _, region := range opts.regions {
+		found := false
+		inside := false
+		for idx, line := range lines {
+			for _, m := range codeMarkerPattern.FindAllStringSubmatchIndex(line, -1) {
+				if line[m[4]:m[5]] != region {
+					continue
+				}
+				found = true
+				if start, seen := markers[idx]; !seen || m[0] < start {
+					markers[idx] = m[0]
+				}
+				inside = line[m[2]:m[3]] == "START"
+				if strings.TrimSpace(line[:m[0]]) != "" {
+					selected[idx] = true
+				}
+			}
+			if inside {
+				selected[idx] = true
+			}
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("region %s not found", region)
+		}
+	}
+	if opts.from != nil || opts.to != nil {
+		start := 0
+		if opts.from != nil {
+			start = -1
+			for idx, line := range lines {
+				if opts.from.MatchString(line) {
+					start = idx
+					break
+				}
+			}
+			if start == -1 {
+				return nil, nil, fmt.Errorf("no line matches %s", opts.from)
+			}
+		}
+		end := len(lines) - 1
+		if opts.to != nil {
+			end = -1
+			for idx := start; idx < len(lines); idx++ {
+				if opts.to.MatchString(lines[idx]) {
+					end = idx
+					break
+				}
+			}
+			if end == -1 {
+				return nil, nil, fmt.Errorf("no line after line %d matches %s", start+1, opts.to)
+			}
+		}
+		for idx := start; idx <= end; idx++ {
+			selected[idx] = true
+		}
+	}
+
+	var kept []string
+	var numbers []int
+	var gaps []bool
+	omitted := false
+	for idx, line := range lines {
+		if start, isMarker := markers[idx]; isMarker {
+			line = strings.TrimRight(line[:start], " \t")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+		}
+		if !selected[idx] {
+			omitted = true
+			continue
+		}
+		kept = append(kept, line)
+		numbers = append(numbers, idx+1)
+		gaps = append(gaps, omitted)
+		omitted = false
+	}
+	if !opts.collapse {
+		return dedent(kept), numbers, nil
+	}
+
+	// The omitted parts are collapsed after dedenting so that the
+	// inserted comments don't affect the indentation.
+	var result []string
+	var resultNumbers []int
+	for idx, line := range dedent(kept) {
+		if gaps[idx] {
+			result = append(result, comment)
+			resultNumbers = append(resultNumbers, 0)
+		}
+		result = append(result, line)
+		resultNumbers = append(resultNumbers, numbers[idx])
+	}
+	if omitted {
+		result = append(result, comment)
+		resultNumbers = append(resultNumbers, 0)
+	}
+	return result, resultNumbers, nil
+}
+
+// dedent removes the indentation shared by all non-empty lines.
+func dedent(lines []string) []string {
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix = indent
+			first = false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if prefix == "" {
+		return lines
+	}
+	result := make([]string, len(lines))
+	for idx, line := range lines {
+		result[idx] = strings.TrimPrefix(line, prefix)
+	}
+	return result
+}
+
+// collapseComment returns the line that replaces omitted code depending on
+// the language of the given file.
+func collapseComment(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".py", ".rb", ".sh", ".bash", ".yml", ".yaml", ".toml", ".pl", ".r", ".ex", ".exs":
+		return "# ..."
+	case ".sql", ".lua", ".hs":
+		return "-- ..."
+	case ".html", ".xml", ".md":
+		return "<!-- ... -->"
+	default:
+		return "// ..."
+	}
+}
+
+func (f *templateFuncs) registerCodeLines(content string, numbers []int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.codeLines == nil {
+		f.codeLines = make(map[string][]int)
+	}
+	f.codeLines[content] = numbers
+}
+
+// sourceLines returns the original line numbers of content returned by
+// loadCode or nil if they are not known.
+func (f *templateFuncs) sourceLines(content string) []int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.codeLines[content]
+}
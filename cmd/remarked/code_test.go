@@ -0,0 +1,137 @@
+package main
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const codeSample = `package main
+
+import "net/http"
+
+func main() {
+	// START handler
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	// END handler
+	log.Print("START server")
+	http.ListenAndServe(":8080", nil)
+}
+`
+
+func TestLoadCode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-code")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(codeSample), 0644))
+	funcs := &templateFuncs{BaseDir: dir}
+
+	tests := []struct {
+		options  []string
+		expected string
+	}{
+		{nil, codeSample},
+		{[]string{"lines=1,3"}, "package main\nimport \"net/http\""},
+		{[]string{"lines=1,3", "collapse"}, "package main\n// ...\nimport \"net/http\"\n// ..."},
+		{[]string{"region=handler"}, "http.HandleFunc(\"/\", func(w http.ResponseWriter, r *http.Request) {\n\tw.Write([]byte(\"hello\"))\n})"},
+		{[]string{"from=^func main", "to=^}"}, "func main() {\n\t// START handler\n\thttp.HandleFunc(\"/\", func(w http.ResponseWriter, r *http.Request) {\n\t\tw.Write([]byte(\"hello\"))\n\t})\n\t// END handler\n\tlog.Print(\"START server\")\n\thttp.ListenAndServe(\":8080\", nil)\n}"},
+		{[]string{"lines=11-12"}, "log.Print(\"START server\")\nhttp.ListenAndServe(\":8080\", nil)"},
+	}
+	for _, test := range tests {
+		content, err := funcs.LoadCode("main.go", test.options...)
+		require.NoError(t, err, "%v", test.options)
+		require.Equal(t, template.HTML(test.expected), content, "%v", test.options)
+	}
+
+	for _, options := range [][]string{{"region=missing"}, {"region=server"}, {"from=nothing"}, {"lines=20"}, {"unknown"}, {"from=("}} {
+		_, err := funcs.LoadCode("main.go", options...)
+		require.Error(t, err, "%v", options)
+	}
+}
+
+func TestLoadCodeInlineMarkers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-code")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	code := "x := 0\nx := 1 // START init\ny := 2\nz := 3 /* END init */\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "init.go"), []byte(code), 0644))
+	funcs := &templateFuncs{BaseDir: dir}
+
+	content, err := funcs.LoadCode("init.go", "region=init")
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("x := 1\ny := 2\nz := 3"), content)
+	marked, err := funcs.MarkLines("3", content)
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("x := 1\n*y := 2\nz := 3"), marked)
+}
+
+func TestMarkLinesWithSourceNumbers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-code")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(codeSample), 0644))
+	funcs := &templateFuncs{BaseDir: dir}
+
+	content, err := funcs.LoadCode("main.go", "region=handler", "collapse")
+	require.NoError(t, err)
+	marked, err := funcs.MarkLines("8", content)
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("// ...\nhttp.HandleFunc(\"/\", func(w http.ResponseWriter, r *http.Request) {\n*\tw.Write([]byte(\"hello\"))\n})\n// ..."), marked)
+
+	marked, err = funcs.MarkLines("2", "a\nb")
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("a\n*b"), marked)
+}
+
+func TestDedent(t *testing.T) {
+	require.Equal(t, []string{"a", "", "  b"}, dedent([]string{"    a", "", "      b"}))
+	require.Equal(t, []string{"a", "\tb"}, dedent([]string{"\ta", "\t\tb"}))
+	require.Equal(t, []string{"a", " b"}, dedent([]string{"a", " b"}))
+}
+
+func TestLoadCodeModes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-code")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "page.html"), []byte("<b>```</b>\n"), 0644))
+	funcs := &templateFuncs{BaseDir: dir}
+
+	tests := []struct {
+		options  []string
+		expected string
+	}{
+		{nil, "<b>```</b>\n"},
+		{[]string{"mode=verbatim"}, "<b>```</b>\n"},
+		{[]string{"mode=escape"}, "&lt;b&gt;```&lt;/b&gt;\n"},
+		{[]string{"mode=fence"}, "````html\n<b>```</b>\n````\n"},
+		{[]string{"lang=xml"}, "````xml\n<b>```</b>\n````\n"},
+		{[]string{"lines=1"}, "<b>```</b>"},
+		{[]string{"lines=1", "mode=escape"}, "&lt;b&gt;```&lt;/b&gt;"},
+		{[]string{"lines=1", "mode=fence"}, "````html\n<b>```</b>\n````"},
+	}
+	for _, test := range tests {
+		content, err := funcs.LoadCode("page.html", test.options...)
+		require.NoError(t, err, "%v", test.options)
+		require.Equal(t, template.HTML(test.expected), content, "%v", test.options)
+	}
+
+	content, err := funcs.LoadCode("page.html", "mode=fence")
+	require.NoError(t, err)
+	marked, err := funcs.MarkLines("1", content)
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("````html\n*<b>```</b>\n````\n"), marked)
+
+	_, err = funcs.LoadCode("page.html", "mode=unknown")
+	require.Error(t, err)
+}
+
+func TestCodeFence(t *testing.T) {
+	require.Equal(t, "```", codeFence([]string{"a", "`b`"}))
+	require.Equal(t, "`````", codeFence([]string{"````", "```"}))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/zerok/remarked/internal/config"
+	"gopkg.in/yaml.v2"
+)
+
+// maskedValue is shown instead of the value of sensitive settings.
+const maskedValue = "********"
+
+// sensitiveKeys are the settings whose values are never printed.
+var sensitiveKeys = map[string]bool{
+	"token": true,
+}
+
+// runConfig implements the config command.
+func runConfig(args []string, cfg *config.Config, sources config.Sources, output io.Writer) error {
+	if len(args) == 1 {
+		switch args[0] {
+		case "show":
+			return showConfig(cfg, sources, output)
+		case "validate":
+			return validateConfig(cfg, output)
+		}
+	}
+	return fmt.Errorf("usage: remarked config show|validate")
+}
+
+// validateConfig prints all problems of the configuration and fails if
+// there are any, so that it can be used in CI pipelines.
+func validateConfig(cfg *config.Config, output io.Writer) error {
+	problems := configProblems(cfg)
+	for _, problem := range problems {
+		fmt.Fprintln(output, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d configuration problem(s) found", len(problems))
+	}
+	return nil
+}
+
+// configProblems returns the problems of the configuration. Once all files
+// exist, the theme and the output template are loaded as well.
+func configProblems(cfg *config.Config) []string {
+	problems := cfg.Validate()
+	if len(problems) > 0 {
+		return problems
+	}
+	th, err := loadTheme(cfg)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if _, err := loadOutputTemplate(cfg.TemplateFile, th); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// showConfig prints every setting of the effective configuration that was
+// set by one of the configuration layers together with its source.
+func showConfig(cfg *config.Config, sources config.Sources, output io.Writer) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	settings := make(map[string]interface{})
+	flattenSettings(config.Normalize(values).(map[string]interface{}), "", settings)
+	if cfg.Token != "" {
+		settings["token"] = cfg.Token
+	}
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		if _, found := sources[key]; found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	w := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, formatSetting(key, settings[key]), sources[key])
+	}
+	return w.Flush()
+}
+
+// flattenSettings collects all values inside the nested mappings by their
+// keys joined with dots.
+func flattenSettings(values map[string]interface{}, prefix string, result map[string]interface{}) {
+	for key, value := range values {
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenSettings(nested, prefix+key+".", result)
+			continue
+		}
+		result[prefix+key] = value
+	}
+}
+
+func formatSetting(key string, value interface{}) string {
+	if sensitiveKeys[key] {
+		return maskedValue
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
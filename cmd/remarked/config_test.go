@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+)
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	userFile := filepath.Join(dir, "user.yml")
+	require.NoError(t, ioutil.WriteFile(userFile, []byte("theme: dark\ntitle: User\n"), 0644))
+	projectFile := filepath.Join(dir, "remarked.yml")
+	require.NoError(t, ioutil.WriteFile(projectFile, []byte("title: Project\nmarkdownFile: slides.md\n"), 0644))
+	defer func(original func() string) {
+		userConfigFile = original
+	}(userConfigFile)
+	userConfigFile = func() string { return userFile }
+	require.NoError(t, os.Setenv("REMARKED_STATIC_FOLDER", "assets"))
+	defer os.Unsetenv("REMARKED_STATIC_FOLDER")
+	log := logrus.New()
+	log.Out = ioutil.Discard
+
+	cfg, sources, err := loadConfig(projectFile, &overrides{Theme: "light"}, dir, log)
+	require.NoError(t, err)
+	require.Equal(t, "Project", cfg.Title)
+	require.Equal(t, "light", cfg.Theme)
+	require.Equal(t, filepath.Join(dir, "assets"), cfg.StaticFolder)
+	require.Equal(t, projectFile, sources["title"])
+	require.Equal(t, "--theme", sources["theme"])
+	require.Equal(t, "$REMARKED_STATIC_FOLDER", sources["staticFolder"])
+	require.Equal(t, config.DefaultSource, sources["leftActionDelimiter"])
+
+	// A missing user configuration file is fine.
+	userConfigFile = func() string { return filepath.Join(dir, "missing.yml") }
+	cfg, _, err = loadConfig(projectFile, &overrides{}, dir, log)
+	require.NoError(t, err)
+	require.Equal(t, "", cfg.Theme)
+
+	cfg.Token = "secret"
+	sources["token"] = "--guide-token"
+	var output bytes.Buffer
+	require.NoError(t, runConfig([]string{"show"}, cfg, sources, &output))
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Equal(t, []string{"KEY", "VALUE", "SOURCE"}, strings.Fields(lines[0]))
+	require.Contains(t, output.String(), "staticFolder")
+	require.Contains(t, output.String(), "$REMARKED_STATIC_FOLDER")
+	require.Contains(t, output.String(), "********")
+	require.NotContains(t, output.String(), "secret")
+	require.Error(t, runConfig(nil, cfg, sources, &output))
+}
+
+func TestLoadConfigUnknownEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	projectFile := filepath.Join(dir, "remarked.yml")
+	require.NoError(t, ioutil.WriteFile(projectFile, []byte("title: Talk\nmarkdownFile: slides.md\nenv: [REMARKED_TEST_SPEAKER]\n"), 0644))
+	defer func(original func() string) {
+		userConfigFile = original
+	}(userConfigFile)
+	userConfigFile = func() string { return "" }
+	require.NoError(t, os.Setenv("REMARKED_TEST_SPEAKER", "Jane"))
+	defer os.Unsetenv("REMARKED_TEST_SPEAKER")
+	require.NoError(t, os.Setenv("REMARKED_THEMES", "dark"))
+	defer os.Unsetenv("REMARKED_THEMES")
+	var output bytes.Buffer
+	log := logrus.New()
+	log.Out = &output
+
+	cfg, _, err := loadConfig(projectFile, &overrides{}, dir, log)
+	require.NoError(t, err)
+	require.Equal(t, []string{"REMARKED_TEST_SPEAKER"}, cfg.Env)
+	require.Contains(t, output.String(), "unknown environment variable REMARKED_THEMES (did you mean REMARKED_THEME?)")
+	require.NotContains(t, output.String(), "REMARKED_TEST_SPEAKER")
+}
+
+func TestFormatSetting(t *testing.T) {
+	require.Equal(t, "slides.md", formatSetting("markdownFile", "slides.md"))
+	require.Equal(t, "[480,960]", formatSetting("images.widths", []interface{}{480, 960}))
+	require.Equal(t, maskedValue, formatSetting("token", "secret"))
+}
+
+func TestValidateConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "slides.md"), []byte("# Hello"), 0644))
+	cfg := &config.Config{
+		MarkdownFile:         filepath.Join(dir, "slides.md"),
+		LeftActionDelimiter:  "{{",
+		RightActionDelimiter: "}}",
+	}
+	var output bytes.Buffer
+	require.NoError(t, runConfig([]string{"validate"}, cfg, nil, &output))
+	require.Empty(t, output.String())
+
+	cfg.TemplateFile = filepath.Join(dir, "template.html")
+	require.NoError(t, ioutil.WriteFile(cfg.TemplateFile, []byte("{{ define \"head\" }}"), 0644))
+	require.EqualError(t, runConfig([]string{"validate"}, cfg, nil, &output), "1 configuration problem(s) found")
+	require.Contains(t, output.String(), "failed to parse template file")
+
+	output.Reset()
+	cfg.TemplateFile = ""
+	cfg.StaticFolder = filepath.Join(dir, "static")
+	require.Error(t, runConfig([]string{"validate"}, cfg, nil, &output))
+	require.Equal(t, "staticFolder: "+cfg.StaticFolder+" does not exist\n", output.String())
+
+	require.EqualError(t, runConfig([]string{"check"}, cfg, nil, &output), "usage: remarked config show|validate")
+}
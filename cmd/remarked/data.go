@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// LoadData loads the given YAML, JSON, TOML or CSV file. The format is
+// determined by the file extension. CSV files are returned as a list of rows
+// with the values of the first row used as keys.
+func (f *templateFuncs) LoadData(path string) (interface{}, error) {
+	path = f.resolveDataPath(path)
+	f.addFile(path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, &result)
+		result = config.Normalize(result)
+	case ".json":
+		err = json.Unmarshal(data, &result)
+	case ".toml":
+		result, err = toml.Unmarshal(data)
+	case ".csv":
+		var rows []map[string]interface{}
+		_, rows, err = parseCSV(data)
+		result = rows
+	default:
+		return nil, fmt.Errorf("unsupported data file %s", path)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	return result, nil
+}
+
+// Table renders the given rows as Markdown table. The data can either be the
+// path to a CSV file or a list of maps like it is returned by LoadData.
+//
+// The optional columns argument is a comma-separated list of the columns to
+// include, each optionally followed by a colon and the header to use for it.
+// If it is empty, all columns are included. The optional sortBy argument
+// names the column to sort by; a leading "-" sorts in descending order.
+func (f *templateFuncs) Table(data interface{}, options ...string) (template.HTML, error) {
+	if len(options) > 2 {
+		return "", fmt.Errorf("table accepts at most a list of columns and a sort column")
+	}
+	var keys []string
+	var rows []map[string]interface{}
+	switch v := data.(type) {
+	case string:
+		path := f.resolveDataPath(v)
+		f.addFile(path)
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		keys, rows, err = parseCSV(raw)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to parse %s", path)
+		}
+	default:
+		var err error
+		rows, err = tableRows(data)
+		if err != nil {
+			return "", err
+		}
+		keys = sortedKeys(rows)
+	}
+
+	headers := keys
+	if len(options) > 0 && strings.TrimSpace(options[0]) != "" {
+		keys, headers = nil, nil
+		for _, column := range strings.Split(options[0], ",") {
+			elems := strings.SplitN(column, ":", 2)
+			key := strings.TrimSpace(elems[0])
+			header := key
+			if len(elems) == 2 {
+				header = strings.TrimSpace(elems[1])
+			}
+			keys = append(keys, key)
+			headers = append(headers, header)
+		}
+	}
+	if len(options) > 1 && options[1] != "" {
+		sortRows(rows, options[1])
+	}
+
+	var out bytes.Buffer
+	writeTableRow(&out, headers)
+	separators := make([]string, len(headers))
+	for i := range separators {
+		separators[i] = "---"
+	}
+	writeTableRow(&out, separators)
+	for _, row := range rows {
+		cells := make([]string, len(keys))
+		for i, key := range keys {
+			if value, ok := row[key]; ok && value != nil {
+				cells[i] = fmt.Sprintf("%v", value)
+			}
+		}
+		writeTableRow(&out, cells)
+	}
+	return template.HTML(strings.TrimSuffix(out.String(), "\n")), nil
+}
+
+// resolveDataPath resolves the path of a data file relative to DataDir or
+// BaseDir if DataDir is not set.
+func (f *templateFuncs) resolveDataPath(path string) string {
+	if f.DataDir == "" || filepath.IsAbs(path) {
+		return f.resolvePath(path)
+	}
+	return filepath.Join(f.DataDir, path)
+}
+
+// parseCSV returns the header and all following rows of the given CSV data.
+func parseCSV(data []byte) ([]string, []map[string]interface{}, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	rows := make([]map[string]interface{}, 0, len(records))
+	if len(records) == 0 {
+		return nil, rows, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, key := range header {
+			row[key] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return header, rows, nil
+}
+
+// tableRows converts the given data into a list of rows. The data is copied
+// so that sorting it doesn't affect other parts of the template.
+func tableRows(data interface{}) ([]map[string]interface{}, error) {
+	var items []interface{}
+	switch v := data.(type) {
+	case []interface{}:
+		items = v
+	case []map[string]interface{}:
+		return append([]map[string]interface{}{}, v...), nil
+	default:
+		return nil, fmt.Errorf("cannot render %T as table", data)
+	}
+	rows := make([]map[string]interface{}, 0, len(items))
+	for idx, item := range items {
+		row, ok := config.Normalize(item).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("row %d is not a map but %T", idx+1, item)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func sortedKeys(rows []map[string]interface{}) []string {
+	seen := make(map[string]struct{})
+	keys := make([]string, 0, 0)
+	for _, row := range rows {
+		for key := range row {
+			if _, found := seen[key]; !found {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortRows sorts the rows by the given column. Values that are numbers are
+// compared numerically. A leading "-" reverses the order.
+func sortRows(rows []map[string]interface{}, column string) {
+	descending := strings.HasPrefix(column, "-")
+	column = strings.TrimPrefix(column, "-")
+	sort.SliceStable(rows, func(i, j int) bool {
+		a := fmt.Sprintf("%v", rows[i][column])
+		b := fmt.Sprintf("%v", rows[j][column])
+		if descending {
+			a, b = b, a
+		}
+		na, errA := strconv.ParseFloat(a, 64)
+		nb, errB := strconv.ParseFloat(b, 64)
+		if errA == nil && errB == nil {
+			return na < nb
+		}
+		return a < b
+	})
+}
+
+func writeTableRow(out *bytes.Buffer, cells []string) {
+	out.WriteString("|")
+	for _, cell := range cells {
+		cell = strings.Replace(cell, "|", "\\|", -1)
+		cell = strings.Replace(cell, "\n", " ", -1)
+		out.WriteString(" " + cell + " |")
+	}
+	out.WriteString("\n")
+}
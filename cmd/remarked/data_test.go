@@ -0,0 +1,75 @@
+package main
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-data")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"speakers.yml":  "- name: Jane\n  talks: 2\n",
+		"speakers.json": `[{"name": "Jane", "talks": 2}]`,
+		"speakers.toml": "[[speaker]]\nname = \"Jane\"\ntalks = 2\n",
+		"speakers.csv":  "name,talks\nJane,2\n",
+	}
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	funcs := &templateFuncs{DataDir: dir}
+
+	data, err := funcs.LoadData("speakers.yml")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{map[string]interface{}{"name": "Jane", "talks": 2}}, data)
+
+	data, err = funcs.LoadData("speakers.json")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{map[string]interface{}{"name": "Jane", "talks": 2.0}}, data)
+
+	data, err = funcs.LoadData("speakers.toml")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"speaker": []interface{}{map[string]interface{}{"name": "Jane", "talks": int64(2)}}}, data)
+
+	data, err = funcs.LoadData("speakers.csv")
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{{"name": "Jane", "talks": "2"}}, data)
+
+	_, err = funcs.LoadData("speakers.txt")
+	require.Error(t, err)
+	require.Len(t, funcs.Files(), 5)
+}
+
+func TestTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-data")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "tools.csv"), []byte("tool,stars,note\nremark,12000,a|b\nreveal,60000,\nimpress,9000,\n"), 0644))
+	funcs := &templateFuncs{DataDir: dir}
+
+	table, err := funcs.Table("tools.csv")
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("| tool | stars | note |\n| --- | --- | --- |\n| remark | 12000 | a\\|b |\n| reveal | 60000 |  |\n| impress | 9000 |  |"), table)
+
+	table, err = funcs.Table("tools.csv", "tool:Tool,stars:Stars", "-stars")
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("| Tool | Stars |\n| --- | --- |\n| reveal | 60000 |\n| remark | 12000 |\n| impress | 9000 |"), table)
+
+	rows := []interface{}{
+		map[interface{}]interface{}{"name": "b", "age": 3},
+		map[interface{}]interface{}{"name": "a"},
+	}
+	table, err = funcs.Table(rows, "", "name")
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("| age | name |\n| --- | --- |\n|  | a |\n| 3 | b |"), table)
+	require.Equal(t, "b", rows[0].(map[interface{}]interface{})["name"], "The original data must not be sorted")
+
+	_, err = funcs.Table(42)
+	require.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"github.com/zerok/remarked/internal/commandchain"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/token"
+)
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+	<title>Presentations</title>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<meta charset="utf-8">
+  </head>
+  <body>
+	<h1>Presentations</h1>
+	<ul>
+	{{ range . }}
+	  <li><a href="{{ .BasePath }}/">{{ .Config.Title }}</a></li>
+	{{ end }}
+	</ul>
+  </body>
+</html>
+`))
+
+// findDecks returns the folders below root that contain a configuration file.
+// The root folder itself is ignored as its path is used for the index page.
+func findDecks(root string) ([]string, error) {
+	result := make([]string, 0, 0)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != defaultConfigFile {
+			return nil
+		}
+		dir := filepath.Dir(path)
+		if dir == filepath.Clean(root) {
+			return nil
+		}
+		result = append(result, dir)
+		return nil
+	})
+	sort.Strings(result)
+	return result, err
+}
+
+// serveDecks creates a handler that serves every presentation found below
+// root under its own path prefix. Every presentation gets its own hub and
+// guide token so that guided sessions stay separate.
+func serveDecks(root string, guide bool, watch bool, log *logrus.Logger) (http.Handler, error) {
+	dirs, err := findDecks(root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to search %s", root)
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no folder below %s contains a %s", root, defaultConfigFile)
+	}
+	mux := http.NewServeMux()
+	decks := make([]*deck, 0, len(dirs))
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		d := &deck{
+			ConfigPath: filepath.Join(dir, defaultConfigFile),
+			Flags:      &overrides{},
+			BasePath:   "/" + filepath.ToSlash(rel),
+			BaseDir:    dir,
+			Hub:        &commandchain.Hub{Log: log},
+			Guide:      guide,
+			Log:        log,
+		}
+		if guide {
+			d.Token = token.Generate()
+		}
+		if watch {
+			d.Reload = &liveReload{Hub: d.Hub, Log: log, Prepare: d.Warm}
+			go d.Reload.Run()
+		}
+		if err := d.Load(); err != nil {
+			return nil, errors.Wrapf(err, "failed to load %s", d.ConfigPath)
+		}
+		go d.watch()
+		if guide {
+			log.Infof("Guide token for %s: %s", d.BasePath, d.Token)
+		}
+		log.Infof("Serving %s under %s/", dir, d.BasePath)
+		mux.Handle(d.BasePath+"/", http.StripPrefix(d.BasePath, d))
+		decks = append(decks, d)
+	}
+	mux.Handle("/", newSecurity(&config.Config{}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := indexTemplate.Execute(w, decks); err != nil {
+			log.WithError(err).Error("Failed to render index")
+		}
+	})))
+	return mux, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDecks(t *testing.T) {
+	root, err := ioutil.TempDir("", "remarked-decks")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"", "talk-a", "nested/talk-b", ".hidden", "no-config"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+		if dir != "no-config" {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(root, dir, defaultConfigFile), []byte(""), 0644))
+		}
+	}
+	decks, err := findDecks(root)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(root, "nested/talk-b"), filepath.Join(root, "talk-a")}, decks)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/zerok/remarked/internal/diagram"
+)
+
+// Diagram converts an ASCII-art drawing into inline SVG.
+func (f *templateFuncs) Diagram(text string) template.HTML {
+	return template.HTML(diagram.ASCII(text))
+}
+
+// SequenceDiagram renders a sequence diagram as inline SVG.
+func (f *templateFuncs) SequenceDiagram(text string) (template.HTML, error) {
+	svg, err := diagram.Sequence(text)
+	if err != nil {
+		return "", fmt.Errorf("sequenceDiagram: %s", err.Error())
+	}
+	return template.HTML(svg), nil
+}
+
+// renderDiagrams replaces fenced code blocks with the language diagram or
+// sequence with the rendered SVG. Fences can be longer than three backticks
+// just like in Markdown.
+func renderDiagrams(content string) (string, error) {
+	if !strings.Contains(content, "```diagram") && !strings.Contains(content, "```sequence") {
+		return content, nil
+	}
+	lines := strings.Split(content, "\n")
+	result := make([]string, 0, len(lines))
+	for idx := 0; idx < len(lines); idx++ {
+		line := strings.TrimRight(lines[idx], " \t\r")
+		fence := line[:len(line)-len(strings.TrimLeft(line, "`"))]
+		kind := line[len(fence):]
+		if len(fence) < 3 || (kind != "diagram" && kind != "sequence") {
+			result = append(result, lines[idx])
+			continue
+		}
+		end := idx + 1
+		for end < len(lines) && strings.TrimRight(lines[end], " \t\r") != fence {
+			end++
+		}
+		if end == len(lines) {
+			return "", fmt.Errorf("line %d: unterminated %s block", idx+1, kind)
+		}
+		text := strings.Join(lines[idx+1:end], "\n")
+		var svg string
+		if kind == "diagram" {
+			svg = diagram.ASCII(text)
+		} else {
+			var err error
+			svg, err = diagram.Sequence(text)
+			if err != nil {
+				return "", fmt.Errorf("line %d: %s", idx+1, err.Error())
+			}
+		}
+		result = append(result, svg)
+		idx = end
+	}
+	return strings.Join(result, "\n"), nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+)
+
+func TestRenderDiagrams(t *testing.T) {
+	content, err := renderDiagrams("# Slide\n\n```diagram\n+--+\n```\n\n````sequence\nA -> B: hi\n````\n\n```go\n+--+\n```")
+	require.NoError(t, err)
+	lines := strings.Split(content, "\n")
+	require.Len(t, lines, 9)
+	require.True(t, strings.HasPrefix(lines[2], `<svg xmlns="http://www.w3.org/2000/svg" class="diagram diagram-ascii"`))
+	require.True(t, strings.HasPrefix(lines[4], `<svg xmlns="http://www.w3.org/2000/svg" class="diagram diagram-sequence"`))
+	require.Equal(t, []string{"```go", "+--+", "```"}, lines[6:])
+
+	_, err = renderDiagrams("text\n```sequence\nA <- B\n```")
+	require.EqualError(t, err, `line 2: line 1: cannot parse "A <- B"`)
+	_, err = renderDiagrams("```diagram\n+--+\n")
+	require.EqualError(t, err, "line 1: unterminated diagram block")
+}
+
+func TestDiagramFuncs(t *testing.T) {
+	cfg := &config.Config{MarkdownAsTemplate: true}
+	content, err := buildContent(`{{ diagram "+--+" }}
+{{ sequenceDiagram "A -> B" }}`, cfg, &templateFuncs{})
+	require.NoError(t, err)
+	require.Contains(t, content, `class="diagram diagram-ascii"`)
+	require.Contains(t, content, `class="diagram diagram-sequence"`)
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/integrity"
+	"github.com/zerok/remarked/internal/remarkjs"
+	"github.com/zerok/remarked/internal/theme"
+	"github.com/zerok/remarked/internal/watcher"
+)
+
+const exportRemarkJSFile = "remark.js"
+const exportStylesheetFile = "style/_.css"
+const exportStaticFolder = "static"
+const exportThemeFolder = "theme"
+
+var staticURLPattern = regexp.MustCompile(`(?m)(^|["'(\s=])/static/`)
+
+type exportOptions struct {
+	// OutputFolder is the folder the static site is written into.
+	OutputFolder string
+
+	// If SingleFile is set, the presentation is written into a single HTML
+	// file at that path instead of the OutputFolder.
+	SingleFile string
+
+	// Watch keeps the export running and rebuilds it whenever one of the
+	// input files changes.
+	Watch bool
+}
+
+func (o exportOptions) target() string {
+	if o.SingleFile != "" {
+		return o.SingleFile
+	}
+	return o.OutputFolder
+}
+
+// runExport writes the presentation to the target specified in the options.
+// If watch is set, the export is repeated whenever one of the input files
+// changes.
+func runExport(cfg *config.Config, opts exportOptions, log *logrus.Logger) error {
+	export := func() ([]string, error) {
+		if opts.SingleFile != "" {
+			return exportSingleFile(cfg, opts.SingleFile, log)
+		}
+		return exportPresentation(cfg, opts.OutputFolder, log)
+	}
+	inputs, err := export()
+	if err != nil {
+		return err
+	}
+	log.Infof("Presentation exported to %s", opts.target())
+	if !opts.Watch {
+		return nil
+	}
+	w := watcher.Watcher{Log: log}
+	w.Add(presentationInputs(cfg)...)
+	w.Add(inputs...)
+	log.Info("Watching for changes")
+	w.Run(nil, func(changed []string) {
+		log.Infof("Rebuilding after changes to %s", strings.Join(changed, ", "))
+		inputs, err := export()
+		// Files included for the first time are watched from now on.
+		w.Add(inputs...)
+		if err != nil {
+			log.WithError(err).Error("Failed to export presentation")
+			return
+		}
+		log.Infof("Presentation exported to %s", opts.target())
+	})
+	return nil
+}
+
+// presentationInputs lists all files and folders that are used to render the
+// presentation. Files included through template functions are not part of
+// this list.
+func presentationInputs(cfg *config.Config) []string {
+	inputs := []string{cfg.MarkdownFile, cfg.TemplateFile, cfg.StaticFolder}
+	if localStylesheet, ok := isLocalFile(cfg.Stylesheet); ok {
+		inputs = append(inputs, localStylesheet)
+	}
+	if _, err := os.Stat(cfg.RemarkJS); err == nil {
+		inputs = append(inputs, cfg.RemarkJS)
+	}
+	if th, err := loadTheme(cfg); err == nil && th != nil && th.Dir != "" {
+		inputs = append(inputs, th.Dir)
+	}
+	return inputs
+}
+
+// exportPresentation renders the presentation once and writes it together
+// with all the files it depends on into the output folder. All references
+// to the files served by remarked are rewritten to relative URLs so that the
+// output folder can be hosted anywhere. The files read on top of the
+// presentationInputs are returned so that they can be watched.
+func exportPresentation(cfg *config.Config, outputFolder string, log *logrus.Logger) ([]string, error) {
+	th, err := loadTheme(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sources := []string{cfg.StaticFolder}
+	if th != nil {
+		sources = append(sources, th.Dir)
+	}
+	for _, src := range sources {
+		if err := checkOutputFolder(outputFolder, src); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(outputFolder, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create %s", outputFolder)
+	}
+	ctx := context{
+		Title:    cfg.Title,
+		RemarkJS: exportRemarkJSFile,
+	}
+
+	if err := fetchRemarkJS(cfg.RemarkJS, cfg.Security.Integrity[cfg.RemarkJS], filepath.Join(outputFolder, exportRemarkJSFile), log); err != nil {
+		return nil, err
+	}
+
+	if localStylesheet, ok := isLocalFile(cfg.Stylesheet); ok {
+		data, err := ioutil.ReadFile(localStylesheet)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read stylesheet %s", localStylesheet)
+		}
+		target := filepath.Join(outputFolder, filepath.FromSlash(exportStylesheetFile))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, errors.Wrapf(err, "failed to create %s", filepath.Dir(target))
+		}
+		if err := ioutil.WriteFile(target, []byte(rewriteStaticURLs(string(data), "../")), 0644); err != nil {
+			return nil, errors.Wrapf(err, "failed to write %s", target)
+		}
+		ctx.StyleSheetURL = exportStylesheetFile
+	} else if cfg.Stylesheet != "" {
+		hash, err := remoteIntegrity(cfg, cfg.Stylesheet, log)
+		if err != nil {
+			return nil, err
+		}
+		ctx.StyleSheetURL = cfg.Stylesheet
+		ctx.StylesheetIntegrity = hash
+	}
+
+	if cfg.StaticFolder != "" {
+		if err := copyFolder(cfg.StaticFolder, filepath.Join(outputFolder, exportStaticFolder), outputFolder); err != nil {
+			return nil, err
+		}
+	}
+
+	if th != nil {
+		if err := exportTheme(th, filepath.Join(outputFolder, exportThemeFolder), outputFolder); err != nil {
+			return nil, err
+		}
+		ctx.ThemeStylesheetURL = exportThemeFolder + "/" + theme.StylesheetFile
+	}
+	ctx.RemarkOptions = remarkOptions(cfg, th)
+
+	content, funcs, err := exportContent(cfg)
+	inputs := exportInputs(th, funcs)
+	if err != nil {
+		return inputs, err
+	}
+	content, err = exportImageVariants(cfg, funcs.ImageVariants(), content, outputFolder)
+	if err != nil {
+		return inputs, err
+	}
+	ctx.Source = rewriteStaticURLs(content, "")
+
+	output, err := exportOutput(cfg, th, &ctx)
+	if err != nil {
+		return inputs, err
+	}
+	target := filepath.Join(outputFolder, "index.html")
+	if err := ioutil.WriteFile(target, output, 0644); err != nil {
+		return inputs, errors.Wrapf(err, "failed to write %s", target)
+	}
+	return inputs, nil
+}
+
+// exportInputs lists the files that were read for the export on top of the
+// presentationInputs, e.g. those included through template functions.
+func exportInputs(th *theme.Theme, funcs *templateFuncs) []string {
+	var inputs []string
+	if th != nil {
+		inputs = append(inputs, th.Files()...)
+	}
+	if funcs != nil {
+		inputs = append(inputs, funcs.Files()...)
+	}
+	return inputs
+}
+
+// exportContent renders the Markdown file of the presentation. The template
+// functions used for it are returned as well.
+func exportContent(cfg *config.Config) (string, *templateFuncs, error) {
+	data, err := ioutil.ReadFile(cfg.MarkdownFile)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "failed to read %s", cfg.MarkdownFile)
+	}
+	funcs := newTemplateFuncs(cfg)
+	content, err := buildContent(string(data), cfg, funcs)
+	return content, funcs, err
+}
+
+// exportOutput renders the output template with the given context.
+func exportOutput(cfg *config.Config, th *theme.Theme, ctx *context) ([]byte, error) {
+	tmpl, err := loadOutputTemplate(cfg.TemplateFile, th)
+	if err != nil {
+		return nil, err
+	}
+	var output bytes.Buffer
+	if err := tmpl.Execute(&output, ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to render output template")
+	}
+	return output.Bytes(), nil
+}
+
+// rewriteStaticURLs turns all absolute references to the /static mountpoint
+// into relative ones starting with the given prefix.
+func rewriteStaticURLs(content string, prefix string) string {
+	return staticURLPattern.ReplaceAllString(content, "${1}"+prefix+exportStaticFolder+"/")
+}
+
+// fetchRemarkJS copies remark.js from the embedded version, a local file or a
+// URL to the given target path. Remote files are only downloaded if the
+// target doesn't exist yet so that rebuilds in watch mode don't hit the
+// network again.
+func fetchRemarkJS(src string, pinned string, target string, log *logrus.Logger) error {
+	if src == "" {
+		return ioutil.WriteFile(target, []byte(remarkjs.Source()), 0644)
+	}
+	if _, err := os.Stat(src); err == nil {
+		return copyFile(src, target)
+	}
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	data, err := readRemarkJS(src, pinned, log)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(target, data, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %s", target)
+	}
+	return nil
+}
+
+// readRemarkJS returns the content of remark.js which is either the embedded
+// version (if src is empty), read from a local file or downloaded. Downloads
+// have to match the pinned integrity unless it is empty.
+func readRemarkJS(src string, pinned string, log *logrus.Logger) ([]byte, error) {
+	if src == "" {
+		return []byte(remarkjs.Source()), nil
+	}
+	if _, err := os.Stat(src); err == nil {
+		return ioutil.ReadFile(src)
+	}
+	data, err := download(src, log)
+	if err != nil {
+		return nil, err
+	}
+	if pinned != "" {
+		if err := integrity.Verify(data, pinned); err != nil {
+			return nil, errors.Wrapf(err, "%s doesn't match its pinned integrity", src)
+		}
+	}
+	return data, nil
+}
+
+// downloadClient is used for all downloads so that an unresponsive server
+// doesn't block remarked forever.
+var downloadClient = &http.Client{Timeout: 30 * time.Second}
+
+// download returns the content of the file at the given URL.
+func download(src string, log *logrus.Logger) ([]byte, error) {
+	log.Infof("Downloading %s", src)
+	resp, err := downloadClient.Get(src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download %s", src)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: unexpected status %s", src, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download %s", src)
+	}
+	return data, nil
+}
+
+// checkOutputFolder makes sure that exporting into the output folder doesn't
+// overwrite the files of the given source folder. An output folder inside
+// the source folder is fine as copyFolder skips it but if the output folder
+// is the source folder or one of its parents, the copies would replace the
+// originals.
+func checkOutputFolder(outputFolder string, src string) error {
+	if src == "" {
+		return nil
+	}
+	absOutput, err := filepath.Abs(outputFolder)
+	if err != nil {
+		return err
+	}
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absOutput, absSrc)
+	if err != nil {
+		return err
+	}
+	if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+		return fmt.Errorf("cannot export into %s as it contains %s. Please choose another output folder", outputFolder, src)
+	}
+	return nil
+}
+
+// copyFolder recursively copies the content of src into target. The exclude
+// folder is skipped which prevents exporting into the static folder itself
+// from recursing endlessly. It must not contain src (see checkOutputFolder).
+func copyFolder(src string, target string, exclude string) error {
+	if err := checkOutputFolder(exclude, src); err != nil {
+		return err
+	}
+	absExclude, err := filepath.Abs(exclude)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if abs, err := filepath.Abs(path); err == nil && abs == absExclude {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(target, rel)
+		if info.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		return copyFile(path, dest)
+	})
+}
+
+func copyFile(src string, target string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", src)
+	}
+	defer in.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", target)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return errors.Wrapf(err, "failed to copy %s to %s", src, target)
+	}
+	return nil
+}
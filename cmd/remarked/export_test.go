@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+)
+
+func TestRewriteStaticURLs(t *testing.T) {
+	input := "![logo](/static/logo.png)\n<img src=\"/static/a.png\">\n/static/b.png\nbackground: url('/static/c.png');\nhttp://example.com/static/d.png"
+	expected := "![logo](static/logo.png)\n<img src=\"static/a.png\">\nstatic/b.png\nbackground: url('static/c.png');\nhttp://example.com/static/d.png"
+	require.Equal(t, expected, rewriteStaticURLs(input, ""))
+	require.Equal(t, "url(../static/c.png)", rewriteStaticURLs("url(/static/c.png)", "../"))
+}
+
+func TestExportPresentation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-export")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	static := filepath.Join(dir, "static")
+	require.NoError(t, os.MkdirAll(filepath.Join(static, "img"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(static, "img", "logo.svg"), []byte("<svg/>"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "remark.js"), []byte("var remark;"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "slides.md"), []byte("# Title\n![logo](/static/img/logo.svg)"), 0644))
+	cfg := &config.Config{
+		MarkdownFile:         filepath.Join(dir, "slides.md"),
+		StaticFolder:         static,
+		RemarkJS:             filepath.Join(dir, "remark.js"),
+		LeftActionDelimiter:  "{{",
+		RightActionDelimiter: "}}",
+	}
+	log := logrus.New()
+	log.Out = ioutil.Discard
+
+	output := filepath.Join(dir, "out")
+	_, err = exportPresentation(cfg, output, log)
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(output, "static", "img", "logo.svg"))
+	require.NoError(t, err)
+	require.Equal(t, "<svg/>", string(data))
+	data, err = ioutil.ReadFile(filepath.Join(output, exportRemarkJSFile))
+	require.NoError(t, err)
+	require.Equal(t, "var remark;", string(data))
+	data, err = ioutil.ReadFile(filepath.Join(output, "index.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "![logo](static/img/logo.svg)")
+
+	// Exporting into a folder inside the static folder skips the output.
+	nested := filepath.Join(static, "out")
+	_, err = exportPresentation(cfg, nested, log)
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(nested, "static", "img", "logo.svg"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(nested, "static", "out"))
+	require.True(t, os.IsNotExist(err))
+
+	// Exporting into the folder containing the static folder would
+	// overwrite it with itself.
+	_, err = exportPresentation(cfg, dir, log)
+	require.Error(t, err)
+	_, err = exportPresentation(cfg, static, log)
+	require.Error(t, err)
+	data, err = ioutil.ReadFile(filepath.Join(static, "img", "logo.svg"))
+	require.NoError(t, err)
+	require.Equal(t, "<svg/>", string(data))
+}
+
+func TestExportPresentationInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-export")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "remark.js"), []byte("var remark;"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "demo.go"), []byte("package main\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "slides.md"), []byte("# Title\n{{ loadCode \"demo.go\" }}"), 0644))
+	cfg := &config.Config{
+		MarkdownFile:         filepath.Join(dir, "slides.md"),
+		BaseDir:              dir,
+		MarkdownAsTemplate:   true,
+		RemarkJS:             filepath.Join(dir, "remark.js"),
+		LeftActionDelimiter:  "{{",
+		RightActionDelimiter: "}}",
+	}
+	log := logrus.New()
+	log.Out = ioutil.Discard
+
+	inputs, err := exportPresentation(cfg, filepath.Join(dir, "out"), log)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "demo.go")}, inputs)
+	inputs, err = exportSingleFile(cfg, filepath.Join(dir, "single.html"), log)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "demo.go")}, inputs)
+}
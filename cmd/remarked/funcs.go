@@ -1,19 +1,127 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
-	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/imaging"
+	"github.com/zerok/remarked/internal/runner"
+	"github.com/zerok/remarked/internal/slides"
 )
 
-type templateFuncs struct{}
+// templateFuncs provides the functions available inside a Markdown file
+// that is used as template. A new instance should be used for every render
+// as it keeps track of all the files that were loaded.
+type templateFuncs struct {
+	// If BaseDir is set, relative paths are resolved against it instead of
+	// the current working directory.
+	BaseDir string
+
+	// DataDir is the folder the paths of data files are resolved against.
+	DataDir string
+
+	// If Lenient is set, missing files passed to loadCode or include are
+	// treated as empty and runCode doesn't execute anything. This is used
+	// by the linter which reports missing files separately.
+	Lenient bool
+
+	// RunCache keeps the output of runCode. If it is nil, nothing is
+	// cached.
+	RunCache *runner.Cache
+
+	// Play keeps the code blocks included with playCode.
+	Play *playground
+
+	// If Images is set, image starts resizing the images it references
+	// in the background, so that they are ready once they are requested.
+	Images *imaging.Cache
+
+	lock  sync.Mutex
+	files []string
+
+	// The following fields are set by buildContent and used for rendering
+	// included files.
+	cfg      *config.Config
+	data     *contentData
+	includes []string
+
+	// codeLines maps content returned by loadCode to the line numbers in
+	// the original file.
+	codeLines map[string][]int
+
+	// imageVariants keeps the resized images referenced by image.
+	imageVariants map[string]imageVariant
+
+	// steps keeps the steps of every stepLines call until they are
+	// expanded into slides.
+	steps [][]step
+}
+
+// newTemplateFuncs creates the template functions for the given
+// presentation. Data files are resolved relative to the folder of the deck
+// or, if that is not set, the folder containing the Markdown file. Results
+// of runCode are cached in the configured folder or the user's cache folder.
+func newTemplateFuncs(cfg *config.Config) *templateFuncs {
+	dataDir := cfg.BaseDir
+	if dataDir == "" {
+		dataDir = filepath.Dir(cfg.MarkdownFile)
+	}
+	funcs := &templateFuncs{BaseDir: cfg.BaseDir, DataDir: dataDir}
+	runCacheDir := cfg.RunCacheDir
+	if runCacheDir == "" {
+		if cacheDir, err := os.UserCacheDir(); err == nil {
+			runCacheDir = filepath.Join(cacheDir, "remarked", "run")
+		}
+	}
+	if runCacheDir != "" {
+		funcs.RunCache = &runner.Cache{Dir: runCacheDir}
+	}
+	return funcs
+}
+
+// Files returns the paths of all files that were loaded through template
+// functions.
+func (f *templateFuncs) Files() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string{}, f.files...)
+}
+
+// resolvePath turns the given path into one relative to BaseDir.
+func (f *templateFuncs) resolvePath(path string) string {
+	if f.BaseDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(f.BaseDir, path)
+}
+
+func (f *templateFuncs) addFile(path string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.files = append(f.files, path)
+}
 
 func (f *templateFuncs) FuncMap() template.FuncMap {
 	return template.FuncMap{
-		"loadCode":  f.LoadCode,
-		"markLines": f.MarkLines,
-		"counter":   f.Counter,
+		"loadCode":        f.LoadCode,
+		"markLines":       f.MarkLines,
+		"stepLines":       f.StepLines,
+		"counter":         f.Counter,
+		"loadData":        f.LoadData,
+		"table":           f.Table,
+		"include":         f.Include,
+		"runCode":         f.RunCode,
+		"playCode":        f.PlayCode,
+		"diagram":         f.Diagram,
+		"sequenceDiagram": f.SequenceDiagram,
+		"image":           f.Image,
 	}
 }
 
@@ -40,26 +148,157 @@ func (f *templateFuncs) Counter(from int, to int, step int) ([]count, error) {
 	return result, nil
 }
 
-func (f *templateFuncs) LoadCode(path string) (template.HTML, error) {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return "", err
+// MarkLines highlights the given lines of data. If data was returned by
+// loadCode, the line numbers refer to the lines of the original file.
+func (f *templateFuncs) MarkLines(lineNumbers, data template.HTML) (template.HTML, error) {
+	return template.HTML(f.markLines(parseLineRanges(string(lineNumbers)), string(data))), nil
+}
+
+// StepLines renders data once for every "|"-separated step, each time with
+// the lines of that step highlighted. Every step gets a copy of the slide
+// the call is on (see expandSteps), all but the first one with count: false.
+// A step can have notes that follow its line ranges after a colon:
+//
+//	stepLines "2-3: Open the file|5|8-9: Close it" (loadCode "main.go" "mode=fence")
+func (f *templateFuncs) StepLines(steps string, data template.HTML) (template.HTML, error) {
+	var slides []step
+	for _, s := range strings.Split(steps, "|") {
+		ranges, notes := s, ""
+		if idx := strings.Index(s, ":"); idx != -1 {
+			ranges, notes = s[:idx], strings.TrimSpace(s[idx+1:])
+		}
+		lineNumbers, errs := parseLineRangesWithErrors(strings.TrimSpace(ranges))
+		if len(errs) > 0 {
+			return "", fmt.Errorf("invalid step %q: %s", s, errs[0].Error())
+		}
+		slides = append(slides, step{content: f.markLines(lineNumbers, string(data)), notes: notes})
 	}
-	return template.HTML(data), nil
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.steps = append(f.steps, slides)
+	return template.HTML(fmt.Sprintf(stepPlaceholder, len(f.steps)-1)), nil
 }
 
-func (f *templateFuncs) MarkLines(lineNumbers, data template.HTML) (template.HTML, error) {
+// step is the content and the presenter notes of one step of stepLines.
+type step struct {
+	content string
+	notes   string
+}
+
+// stepPlaceholder is rendered by stepLines in place of the steps. It is
+// replaced by expandSteps once the whole slide is known.
+const stepPlaceholder = "\x00stepLines %d\x00"
+
+var stepPlaceholderPattern = regexp.MustCompile(`\x00stepLines (\d+)\x00`)
+
+// expandSteps repeats every slide that contains a call to stepLines once for
+// each of its steps. The notes of a step are appended to the notes of the
+// slide.
+func (f *templateFuncs) expandSteps(content string) (string, error) {
+	f.lock.Lock()
+	steps := f.steps
+	f.steps = nil
+	f.lock.Unlock()
+	if len(steps) == 0 {
+		return content, nil
+	}
+	chunks, notes := splitSlides(content)
+	result := make([]string, 0, len(chunks))
+	for n, slide := range chunks {
+		matches := stepPlaceholderPattern.FindAllStringSubmatchIndex(slide, -1)
+		if len(matches) == 0 {
+			result = append(result, slide)
+			continue
+		}
+		if len(matches) > 1 {
+			return "", fmt.Errorf("stepLines can only be used once per slide")
+		}
+		idx, _ := strconv.Atoi(slide[matches[0][2]:matches[0][3]])
+		before, after := slide[:matches[0][0]], slide[matches[0][1]:]
+		for i, s := range steps[idx] {
+			text := before + s.content + after
+			if s.notes != "" {
+				body := strings.TrimRight(text, "\n")
+				separator := "\n???\n"
+				if notes[n] {
+					separator = "\n\n"
+				}
+				text = body + separator + s.notes + text[len(body):]
+			}
+			if i > 0 {
+				text = "count: false\n" + withoutName(text)
+			}
+			result = append(result, text)
+		}
+	}
+	return strings.Join(result, "\n---\n"), nil
+}
+
+// withoutName removes the name property of the given slide. Only the first
+// step of a slide keeps it so that links to the name stay unambiguous.
+func withoutName(slide string) string {
+	parsed := slides.Parse(slide)
+	if len(parsed) == 0 {
+		return slide
+	}
+	for _, p := range parsed[0].Properties {
+		if p.Name == "name" {
+			lines := strings.Split(slide, "\n")
+			return strings.Join(append(lines[:p.Line-1], lines[p.Line:]...), "\n")
+		}
+	}
+	return slide
+}
+
+// splitSlides splits the content at the --- separators found by
+// slides.Parse. Incremental steps stay part of their slide. For every slide
+// it also reports whether its last step has presenter notes.
+func splitSlides(content string) ([]string, []bool) {
+	lines := strings.Split(content, "\n")
+	var chunks []string
+	var notes []bool
+	start := 0
+	hasNotes := false
+	for idx, slide := range slides.Parse(content) {
+		if idx > 0 && !slide.Incremental {
+			chunks = append(chunks, strings.Join(lines[start:slide.Line-2], "\n"))
+			notes = append(notes, hasNotes)
+			start = slide.Line - 1
+		}
+		hasNotes = slide.NotesLine != 0
+	}
+	return append(chunks, strings.Join(lines[start:], "\n")), append(notes, hasNotes)
+}
+
+func (f *templateFuncs) markLines(lineNumbers map[int]struct{}, data string) string {
 	var result []string
-	parsedLineNumbers := parseLineRanges(string(lineNumbers))
-	for idx, line := range strings.Split(string(data), "\n") {
-		_, highlight := parsedLineNumbers[idx+1]
+	sourceLines := f.sourceLines(data)
+	for idx, line := range strings.Split(data, "\n") {
+		number := idx + 1
+		if sourceLines != nil {
+			number = 0
+			if idx < len(sourceLines) {
+				number = sourceLines[idx]
+			}
+		}
+		_, highlight := lineNumbers[number]
 		result = append(result, highlightLine(line, highlight))
 	}
-	return template.HTML(strings.Join(result, "\n")), nil
+	return strings.Join(result, "\n")
 }
 
+// parseLineRanges parses a comma-separated list of line numbers and
+// START-STOP ranges. Segments that cannot be parsed are skipped.
 func parseLineRanges(ranges string) map[int]struct{} {
+	result, _ := parseLineRangesWithErrors(ranges)
+	return result
+}
+
+// parseLineRangesWithErrors works like parseLineRanges but also returns an
+// error for every segment that had to be skipped.
+func parseLineRangesWithErrors(ranges string) (map[int]struct{}, []error) {
 	result := make(map[int]struct{})
+	var errs []error
 	rangeSegments := strings.Split(ranges, ",")
 	for _, r := range rangeSegments {
 		segments := strings.SplitN(r, "-", 2)
@@ -69,31 +308,47 @@ func parseLineRanges(ranges string) map[int]struct{} {
 		case 1:
 			line, err := strconv.ParseInt(r, 10, 32)
 			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid line number %q", r))
 				continue
 			}
 			start, end = line, line
 		case 2:
 			start, err = strconv.ParseInt(segments[0], 10, 32)
 			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid start of range %q", r))
 				continue
 			}
 			end, err = strconv.ParseInt(segments[1], 10, 32)
 			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid end of range %q", r))
 				continue
 			}
 		}
 
-		if start != 0 {
-			for i := start; i <= end; i++ {
-				result[int(i)] = struct{}{}
-			}
+		if start <= 0 {
+			errs = append(errs, fmt.Errorf("line numbers start at 1 in %q", r))
+			continue
+		}
+		if end < start {
+			errs = append(errs, fmt.Errorf("end of range %q is before its start", r))
+			continue
+		}
+		for i := start; i <= end; i++ {
+			result[int(i)] = struct{}{}
 		}
 	}
-	return result
+	return result, errs
 }
 
+// highlightLine prefixes the line with a "*" which remark.js uses for
+// highlighting. Lines that already start with a "*" but should not be
+// highlighted are indented by a space so that remark.js keeps them as they
+// are.
 func highlightLine(line string, doHighlight bool) string {
 	if !doHighlight {
+		if strings.HasPrefix(line, "*") {
+			return " " + line
+		}
 		return line
 	}
 	return "*" + strings.TrimPrefix(line, " ")
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
 )
 
 func TestParseLineRanges(t *testing.T) {
@@ -17,3 +19,66 @@ func TestParseLineRanges(t *testing.T) {
 	}
 	require.Equal(t, expected, result)
 }
+
+func TestParseLineRangesWithErrors(t *testing.T) {
+	result, errs := parseLineRangesWithErrors("2-3,x,5-4,0,7")
+	expected := map[int]struct{}{
+		2: struct{}{},
+		3: struct{}{},
+		7: struct{}{},
+	}
+	require.Equal(t, expected, result)
+	require.Len(t, errs, 3)
+}
+
+func TestBuildContentData(t *testing.T) {
+	os.Setenv("REMARKED_TEST_SPEAKER", "Jane")
+	defer os.Unsetenv("REMARKED_TEST_SPEAKER")
+	cfg := &config.Config{
+		Title:                "Talk",
+		Token:                "secret",
+		MarkdownAsTemplate:   true,
+		LeftActionDelimiter:  "{{",
+		RightActionDelimiter: "}}",
+		Data:                 map[string]interface{}{"event": "GopherCon"},
+		Env:                  []string{"REMARKED_TEST_SPEAKER"},
+	}
+	content, err := buildContent(`{{ .Config.Title }} at {{ .Data.event }} by {{ .Env.REMARKED_TEST_SPEAKER }}{{ .Config.Token }}`, cfg, newTemplateFuncs(cfg))
+	require.NoError(t, err)
+	require.Equal(t, "Talk at GopherCon by Jane", content)
+	require.Equal(t, "secret", cfg.Token)
+}
+
+func TestHighlightLine(t *testing.T) {
+	require.Equal(t, "*a", highlightLine(" a", true))
+	require.Equal(t, "**/", highlightLine("*/", true))
+	require.Equal(t, " */", highlightLine("*/", false))
+	require.Equal(t, "a", highlightLine("a", false))
+}
+
+func TestStepLines(t *testing.T) {
+	cfg := &config.Config{
+		MarkdownAsTemplate:   true,
+		LeftActionDelimiter:  "{{",
+		RightActionDelimiter: "}}",
+	}
+	content := "# First\n---\nclass: code\n# Title\n{{ stepLines \"1|2-3: Second step\" \"a\\nb\\n* c\" }}\nAfter\n```\n---\n```\n---\n# Last\n"
+	result, err := buildContent(content, cfg, &templateFuncs{})
+	require.NoError(t, err)
+	require.Equal(t, "# First\n---\nclass: code\n# Title\n*a\nb\n * c\nAfter\n```\n---\n```\n---\ncount: false\nclass: code\n# Title\na\n*b\n** c\nAfter\n```\n---\n```\n???\nSecond step\n---\n# Last\n", result)
+
+	result, err = buildContent("{{ stepLines \"1: One|1: Two\" \"a\" }}\n???\nNotes\n", cfg, &templateFuncs{})
+	require.NoError(t, err)
+	require.Equal(t, "*a\n???\nNotes\n\nOne\n\n---\ncount: false\n*a\n???\nNotes\n\nTwo\n", result)
+
+	// Only the first step keeps the name of the slide.
+	result, err = buildContent("name: steps\nclass: code\n{{ stepLines \"1|2\" \"a\\nb\" }}\n---\n[Steps](#steps)\n", cfg, &templateFuncs{})
+	require.NoError(t, err)
+	require.Equal(t, "name: steps\nclass: code\n*a\nb\n---\ncount: false\nclass: code\na\n*b\n---\n[Steps](#steps)\n", result)
+
+	_, err = buildContent("{{ stepLines \"1|2\" \"a\" }}\n{{ stepLines \"1|2\" \"a\" }}", cfg, &templateFuncs{})
+	require.Error(t, err)
+
+	_, err = (&templateFuncs{}).StepLines("1|x", "a")
+	require.Error(t, err)
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"time"
 
@@ -10,6 +9,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/zerok/remarked/internal/commandchain"
 	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/imaging"
 )
 
 func guidedWebsocketHandler(cfg *config.Config, hub *commandchain.Hub, log *logrus.Logger) http.HandlerFunc {
@@ -59,39 +59,13 @@ func guideWebsocketHandler(cfg *config.Config, hub *commandchain.Hub, log *logru
 	}
 }
 
-func guideHandler(cfg *config.Config, log *logrus.Logger) http.HandlerFunc {
+func guideHandler(cfg *config.Config, cache *renderCache, play *playground, images *imaging.Cache, reload *liveReload, log *logrus.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tmpl, err := loadOutputTemplate(cfg.TemplateFile)
-		if err != nil {
-			log.WithError(err).Errorf("Failed to parse template ")
-			http.Error(w, "Failed to parse template file", http.StatusInternalServerError)
-			return
-		}
-		data, err := ioutil.ReadFile(cfg.MarkdownFile)
-		if err != nil {
-			log.WithError(err).Errorf("Failed to read %s", cfg.MarkdownFile)
-			http.Error(w, "Failed to read file", http.StatusInternalServerError)
-			return
-		}
-		funcs := templateFuncs{}
-		ctx := context{
-			RemarkJS:      cfg.RemarkJS,
-			StyleSheetURL: cfg.FinalStylesheet,
-			Title:         cfg.Title,
-			IsGuided:      true,
-			IsGuide:       true,
-			Token:         cfg.Token,
-		}
-
-		rawData := string(data)
-		content, err := buildContent(rawData, cfg, funcs.FuncMap())
-		if err != nil {
-			log.WithError(err).Error("Failed to compile content")
-			http.Error(w, "Failed to compile output", http.StatusInternalServerError)
-			return
-		}
-		ctx.Source = content
-		tmpl.Execute(w, ctx)
+		ctx := presentationContext(cfg, true, play, images, reload)
+		ctx.IsGuide = true
+		ctx.Token = cfg.Token
+		ctx.Runnable = cfg.Play.Enabled
+		servePage(w, r, "guide", cfg, ctx, cache, reload, log)
 	}
 }
 
@@ -99,8 +73,14 @@ func guideLoginHandler(cfg *config.Config, log *logrus.Logger) http.HandlerFunc
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
 			if r.FormValue("token") == cfg.Token {
-				w.Header().Set("Set-Cookie", fmt.Sprintf("guideToken=%s", cfg.Token))
-				w.Header().Set("Location", "/guide")
+				// The cookie is also needed for the websocket endpoints
+				// outside of /guide.
+				cookie := fmt.Sprintf("guideToken=%s; Path=%s/", cfg.Token, cfg.BasePath)
+				if r.TLS != nil {
+					cookie += "; Secure"
+				}
+				w.Header().Set("Set-Cookie", cookie)
+				w.Header().Set("Location", cfg.BasePath+"/guide")
 				w.WriteHeader(307)
 				return
 			}
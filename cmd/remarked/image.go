@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/imaging"
+)
+
+// resizedFolder is the folder resized images are written to when exporting
+// the presentation.
+const resizedFolder = "resized"
+
+var srcsetPattern = regexp.MustCompile(`\ssrcset="[^"]*\?w=[^"]*"(\ssizes="[^"]*")?`)
+
+// imageVariant is a resized version of an image referenced by the image
+// template function.
+type imageVariant struct {
+	File  string
+	Width int
+}
+
+// Image renders an img element for the image at the given path inside the
+// static folder. It lists resized versions of the image in the configured
+// widths as srcset so that browsers only download what they need and sets
+// the dimensions so that the layout doesn't jump while loading. The
+// following options are supported:
+//
+//	alt=TEXT        the alternative text
+//	class=CLASS     the class of the img element
+//	sizes=SIZES     the sizes attribute (default: 100vw)
+func (f *templateFuncs) Image(name string, options ...string) (template.HTML, error) {
+	if f.cfg == nil {
+		return "", fmt.Errorf("image can only be used inside Markdown templates")
+	}
+	if f.cfg.StaticFolder == "" {
+		return "", fmt.Errorf("image requires a static folder")
+	}
+	attrs := map[string]string{"sizes": "100vw"}
+	for _, option := range options {
+		elems := strings.SplitN(option, "=", 2)
+		switch {
+		case len(elems) == 2 && (elems[0] == "alt" || elems[0] == "class" || elems[0] == "sizes"):
+			attrs[elems[0]] = elems[1]
+		default:
+			return "", fmt.Errorf("image: unknown option %s", option)
+		}
+	}
+	name = path.Clean("/" + strings.TrimPrefix(filepath.ToSlash(name), "/static/"))
+	file, err := filepath.Abs(filepath.Join(f.cfg.StaticFolder, filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+	f.addFile(file)
+	if f.Lenient {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			return "", nil
+		}
+	}
+	if !imaging.Supported(file) {
+		return "", fmt.Errorf("image: unsupported image format %s", filepath.Ext(file))
+	}
+	info, err := imaging.Stat(file)
+	if err != nil {
+		return "", err
+	}
+
+	src := (&url.URL{Path: "/static" + name}).EscapedPath()
+	width, height := info.Width, info.Height
+	var srcset []string
+	var resized []int
+	widths := imageWidths(f.cfg)
+	for _, w := range widths {
+		if w >= info.Width {
+			break
+		}
+		variant := fmt.Sprintf("%s?w=%d", src, w)
+		f.addImageVariant(variant, imageVariant{File: file, Width: w})
+		srcset = append(srcset, fmt.Sprintf("%s %dw", variant, w))
+		resized = append(resized, w)
+	}
+	if f.Images != nil && len(resized) > 0 {
+		f.Images.Prefetch(file, resized)
+	}
+	if len(srcset) > 0 {
+		if largest := widths[len(widths)-1]; info.Width > largest {
+			width = largest
+			height = (info.Height*largest + info.Width/2) / info.Width
+			src = fmt.Sprintf("%s?w=%d", src, largest)
+		} else {
+			srcset = append(srcset, fmt.Sprintf("%s %dw", src, info.Width))
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, `<img src="%s"`, template.HTMLEscapeString(src))
+	if len(srcset) > 0 {
+		fmt.Fprintf(&out, ` srcset="%s" sizes="%s"`, template.HTMLEscapeString(strings.Join(srcset, ", ")), template.HTMLEscapeString(attrs["sizes"]))
+	}
+	fmt.Fprintf(&out, ` width="%d" height="%d" alt="%s"`, width, height, template.HTMLEscapeString(attrs["alt"]))
+	if class := attrs["class"]; class != "" {
+		fmt.Fprintf(&out, ` class="%s"`, template.HTMLEscapeString(class))
+	}
+	out.WriteString(">")
+	return template.HTML(out.String()), nil
+}
+
+func (f *templateFuncs) addImageVariant(url string, variant imageVariant) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.imageVariants == nil {
+		f.imageVariants = make(map[string]imageVariant)
+	}
+	f.imageVariants[url] = variant
+}
+
+// ImageVariants returns the resized images referenced by the image template
+// function by their URL.
+func (f *templateFuncs) ImageVariants() map[string]imageVariant {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	result := make(map[string]imageVariant, len(f.imageVariants))
+	for url, variant := range f.imageVariants {
+		result[url] = variant
+	}
+	return result
+}
+
+// imageWidths returns the configured widths images are resized to in
+// ascending order.
+func imageWidths(cfg *config.Config) []int {
+	if len(cfg.Images.Widths) == 0 {
+		return imaging.DefaultWidths
+	}
+	widths := append([]int{}, cfg.Images.Widths...)
+	sort.Ints(widths)
+	return widths
+}
+
+// newImageCache creates the cache for resized images. If no cache folder is
+// configured, the user's cache folder is used.
+func newImageCache(cfg *config.Config) (*imaging.Cache, error) {
+	dir := cfg.Images.CacheDir
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to determine cache folder for images")
+		}
+		dir = filepath.Join(cacheDir, "remarked", "images")
+	}
+	return &imaging.Cache{Dir: dir, Quality: cfg.Images.Quality}, nil
+}
+
+// staticHandler serves the static folder. Requests for images with a w
+// query parameter are served from the image cache with the image resized to
+// that width. Only the configured widths are available.
+func staticHandler(folder string, cfg *config.Config, images *imaging.Cache, log *logrus.Logger) http.Handler {
+	files := http.FileServer(http.Dir(folder))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		param := r.URL.Query().Get("w")
+		name := filepath.Join(folder, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if param == "" || !imaging.Supported(name) {
+			files.ServeHTTP(w, r)
+			return
+		}
+		width, err := strconv.Atoi(param)
+		if err != nil || !containsInt(imageWidths(cfg), width) {
+			http.Error(w, "Unsupported image width", http.StatusBadRequest)
+			return
+		}
+		resized, err := images.Resize(name, width)
+		if err != nil {
+			if os.IsNotExist(err) {
+				http.NotFound(w, r)
+				return
+			}
+			log.WithError(err).Errorf("Failed to resize %s", name)
+			http.Error(w, "Failed to resize image", http.StatusInternalServerError)
+			return
+		}
+		fp, err := os.Open(resized)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to open %s", resized)
+			http.Error(w, "Failed to resize image", http.StatusInternalServerError)
+			return
+		}
+		defer fp.Close()
+		stat, err := fp.Stat()
+		if err != nil {
+			http.Error(w, "Failed to resize image", http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, name, stat.ModTime(), fp)
+	})
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// resizeImageVariants resizes all the given images and returns the paths of
+// the resized files by URL.
+func resizeImageVariants(cfg *config.Config, variants map[string]imageVariant) (map[string]string, error) {
+	result := make(map[string]string, len(variants))
+	if len(variants) == 0 {
+		return result, nil
+	}
+	images, err := newImageCache(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for url, variant := range variants {
+		resized, err := images.Resize(variant.File, variant.Width)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resize %s", variant.File)
+		}
+		result[url] = resized
+	}
+	return result, nil
+}
+
+// exportImageVariants writes the resized images referenced inside the
+// content into the output folder and points the references to them.
+func exportImageVariants(cfg *config.Config, variants map[string]imageVariant, content string, outputFolder string) (string, error) {
+	resized, err := resizeImageVariants(cfg, variants)
+	if err != nil {
+		return "", err
+	}
+	// Longer URLs are replaced first as ?w=480 is a prefix of ?w=4800.
+	urls := make([]string, 0, len(resized))
+	for url := range resized {
+		urls = append(urls, url)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		return len(urls[i]) > len(urls[j])
+	})
+	for _, u := range urls {
+		elems := strings.SplitN(u, "?w=", 2)
+		escaped := strings.TrimPrefix(elems[0], "/static/")
+		// The URL stays escaped inside the content but the file is
+		// written with its actual name.
+		name, err := url.PathUnescape(escaped)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid image URL %s", u)
+		}
+		rel := path.Join(resizedFolder, elems[1], escaped)
+		target := filepath.Join(outputFolder, resizedFolder, elems[1], filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return "", errors.Wrapf(err, "failed to create %s", filepath.Dir(target))
+		}
+		if err := copyFile(resized[u], target); err != nil {
+			return "", err
+		}
+		content = strings.Replace(content, u, rel, -1)
+	}
+	return content, nil
+}
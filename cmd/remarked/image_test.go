@@ -0,0 +1,125 @@
+package main
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/imaging"
+)
+
+func writePNG(t *testing.T, path string, width, height int) {
+	fp, err := os.Create(path)
+	require.NoError(t, err)
+	defer fp.Close()
+	require.NoError(t, png.Encode(fp, image.NewRGBA(image.Rect(0, 0, width, height))))
+}
+
+func TestImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-image")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writePNG(t, filepath.Join(dir, "wide.png"), 400, 200)
+	writePNG(t, filepath.Join(dir, "medium image.png"), 150, 100)
+	writePNG(t, filepath.Join(dir, "small.png"), 50, 50)
+	cfg := &config.Config{
+		StaticFolder: dir,
+		Images:       config.Images{Widths: []int{200, 100}},
+	}
+	funcs := &templateFuncs{cfg: cfg}
+
+	output, err := funcs.Image("wide.png", "alt=A \"wide\" image", "class=full")
+	require.NoError(t, err)
+	require.Equal(t, `<img src="/static/wide.png?w=200" srcset="/static/wide.png?w=100 100w, /static/wide.png?w=200 200w" sizes="100vw" width="200" height="100" alt="A &#34;wide&#34; image" class="full">`, string(output))
+
+	output, err = funcs.Image("/static/medium image.png", "sizes=50vw")
+	require.NoError(t, err)
+	require.Equal(t, `<img src="/static/medium%20image.png" srcset="/static/medium%20image.png?w=100 100w, /static/medium%20image.png 150w" sizes="50vw" width="150" height="100" alt="">`, string(output))
+
+	output, err = funcs.Image("small.png")
+	require.NoError(t, err)
+	require.Equal(t, `<img src="/static/small.png" width="50" height="50" alt="">`, string(output))
+
+	require.Equal(t, map[string]imageVariant{
+		"/static/wide.png?w=100":           {File: filepath.Join(dir, "wide.png"), Width: 100},
+		"/static/wide.png?w=200":           {File: filepath.Join(dir, "wide.png"), Width: 200},
+		"/static/medium%20image.png?w=100": {File: filepath.Join(dir, "medium image.png"), Width: 100},
+	}, funcs.ImageVariants())
+	require.Len(t, funcs.Files(), 3)
+
+	_, err = funcs.Image("missing.png")
+	require.Error(t, err)
+	_, err = funcs.Image("small.png", "width=10")
+	require.EqualError(t, err, "image: unknown option width=10")
+}
+
+func TestStaticHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-image")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	static := filepath.Join(dir, "static")
+	require.NoError(t, os.MkdirAll(static, 0755))
+	writePNG(t, filepath.Join(static, "wide.png"), 400, 200)
+	cfg := &config.Config{Images: config.Images{Widths: []int{100}}}
+	handler := staticHandler(static, cfg, &imaging.Cache{Dir: filepath.Join(dir, "cache")}, logrus.New())
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+	w := get("/wide.png?w=100")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	img, err := png.Decode(w.Body)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 100, 50), img.Bounds())
+
+	w = get("/wide.png")
+	require.Equal(t, http.StatusOK, w.Code)
+	img, err = png.Decode(w.Body)
+	require.NoError(t, err)
+	require.Equal(t, 400, img.Bounds().Dx())
+
+	require.Equal(t, http.StatusBadRequest, get("/wide.png?w=123").Code)
+	require.Equal(t, http.StatusNotFound, get("/missing.png?w=100").Code)
+	// Files outside of the static folder can't be reached.
+	writePNG(t, filepath.Join(dir, "outside.png"), 400, 200)
+	require.Equal(t, http.StatusNotFound, get("/../outside.png?w=100").Code)
+}
+
+func TestExportImageVariants(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-image")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writePNG(t, filepath.Join(dir, "wide.png"), 400, 200)
+	cfg := &config.Config{Images: config.Images{CacheDir: filepath.Join(dir, "cache")}}
+	variants := map[string]imageVariant{
+		"/static/wide.png?w=10":  {File: filepath.Join(dir, "wide.png"), Width: 10},
+		"/static/wide.png?w=100": {File: filepath.Join(dir, "wide.png"), Width: 100},
+	}
+	output := filepath.Join(dir, "output")
+	content, err := exportImageVariants(cfg, variants, `<img src="/static/wide.png?w=100" srcset="/static/wide.png?w=10 10w, /static/wide.png?w=100 100w">`, output)
+	require.NoError(t, err)
+	require.Equal(t, `<img src="resized/100/wide.png" srcset="resized/10/wide.png 10w, resized/100/wide.png 100w">`, content)
+	_, err = os.Stat(filepath.Join(output, "resized", "10", "wide.png"))
+	require.NoError(t, err)
+
+	writePNG(t, filepath.Join(dir, "my photo.png"), 400, 200)
+	variants = map[string]imageVariant{
+		"/static/my%20photo.png?w=10": {File: filepath.Join(dir, "my photo.png"), Width: 10},
+	}
+	content, err = exportImageVariants(cfg, variants, `<img src="/static/my%20photo.png?w=10">`, output)
+	require.NoError(t, err)
+	require.Equal(t, `<img src="resized/10/my%20photo.png">`, content)
+	_, err = os.Stat(filepath.Join(output, "resized", "10", "my photo.png"))
+	require.NoError(t, err)
+}
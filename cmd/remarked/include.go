@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var templateErrorPattern = regexp.MustCompile(`(?:html/template:|template: )(.+?):(\d+):(?:\d+:)? ?`)
+
+// contentError is returned if the Markdown file or one of the files included
+// from it cannot be rendered. File and Line point to the innermost location
+// the error was reported for.
+type contentError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *contentError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// newContentError extracts the file and line from the given template error.
+// As included files are rendered as templates named after their path, the
+// last location mentioned in the error is the one closest to the problem.
+func newContentError(err error) error {
+	msg := err.Error()
+	matches := templateErrorPattern.FindAllStringSubmatchIndex(msg, -1)
+	if len(matches) == 0 {
+		return fmt.Errorf("Failed to render template: %s", msg)
+	}
+	m := matches[len(matches)-1]
+	line, _ := strconv.Atoi(msg[m[4]:m[5]])
+	return &contentError{
+		File:    msg[m[2]:m[3]],
+		Line:    line,
+		Message: msg[m[1]:],
+	}
+}
+
+// render executes the given content as template using the delimiters of the
+// configuration.
+func (f *templateFuncs) render(name string, content string, data *contentData) (string, error) {
+	tmpl, err := template.New(name).
+		Funcs(f.FuncMap()).
+		Delims(f.cfg.LeftActionDelimiter, f.cfg.RightActionDelimiter).
+		Parse(content)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Include renders the Markdown file at the given path (relative to the file
+// it is included from) as template. All further arguments are passed to it as
+// key-value pairs available as .Args.
+func (f *templateFuncs) Include(path string, args ...interface{}) (template.HTML, error) {
+	if f.cfg == nil {
+		return "", fmt.Errorf("include can only be used inside Markdown templates")
+	}
+	if len(args)%2 != 0 {
+		return "", fmt.Errorf("include expects arguments as key-value pairs")
+	}
+	data := *f.data
+	data.Args = make(map[string]interface{}, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok {
+			return "", fmt.Errorf("include expects argument names to be strings, got %T", args[i])
+		}
+		data.Args[key] = args[i+1]
+	}
+
+	path = resolveIncludePath(f.includes[len(f.includes)-1], path)
+	for idx, included := range f.includes {
+		if included == path {
+			cycle := append(append([]string{}, f.includes[idx:]...), path)
+			return "", fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	f.addFile(path)
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		if f.Lenient && os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	f.includes = append(f.includes, path)
+	defer func() {
+		f.includes = f.includes[:len(f.includes)-1]
+	}()
+	content, err := f.render(path, string(raw), &data)
+	return template.HTML(content), err
+}
+
+// resolveIncludePath resolves the path passed to include relative to the
+// file it is included from.
+func resolveIncludePath(current string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(current), path)
+}
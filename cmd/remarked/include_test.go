@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+)
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-include")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "parts"), 0755))
+	files := map[string]string{
+		"parts/intro.md":  `<< .Config.Title >>: << .Args.topic >> << include "outro.md" >>`,
+		"parts/outro.md":  `Bye`,
+		"parts/broken.md": "first\n<< .Config.Missing >>",
+		"parts/a.md":      `<< include "b.md" >>`,
+		"parts/b.md":      `<< include "a.md" >>`,
+	}
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	cfg := &config.Config{
+		Title:                "Talk",
+		MarkdownFile:         filepath.Join(dir, "slides.md"),
+		MarkdownAsTemplate:   true,
+		LeftActionDelimiter:  "<<",
+		RightActionDelimiter: ">>",
+	}
+
+	funcs := newTemplateFuncs(cfg)
+	content, err := buildContent(`<< include "parts/intro.md" "topic" "Go" >>`, cfg, funcs)
+	require.NoError(t, err)
+	require.Equal(t, "Talk: Go Bye", content)
+	require.Equal(t, []string{filepath.Join(dir, "parts/intro.md"), filepath.Join(dir, "parts/outro.md")}, funcs.Files())
+
+	_, err = buildContent("\n<< include \"parts/broken.md\" >>", cfg, newTemplateFuncs(cfg))
+	require.Error(t, err)
+	cerr, ok := err.(*contentError)
+	require.True(t, ok)
+	require.Equal(t, filepath.Join(dir, "parts/broken.md"), cerr.File)
+	require.Equal(t, 2, cerr.Line)
+
+	_, err = buildContent(`<< include "parts/a.md" >>`, cfg, newTemplateFuncs(cfg))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "include cycle: "+filepath.Join(dir, "parts/a.md")+" -> "+filepath.Join(dir, "parts/b.md")+" -> "+filepath.Join(dir, "parts/a.md"))
+
+	_, err = buildContent(`<< include "parts/intro.md" "topic" >>`, cfg, newTemplateFuncs(cfg))
+	require.Error(t, err)
+}
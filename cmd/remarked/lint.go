@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/slides"
+)
+
+var lintLoadCodePattern = regexp.MustCompile(`loadCode\s+"([^"]*)"`)
+var lintLoadDataPattern = regexp.MustCompile(`loadData\s+"([^"]*)"`)
+var lintIncludePattern = regexp.MustCompile(`include\s+"([^"]*)"`)
+var lintMarkLinesPattern = regexp.MustCompile(`markLines\s+"([^"]*)"`)
+var lintStepLinesPattern = regexp.MustCompile(`stepLines\s+"([^"]*)"`)
+var lintImagePattern = regexp.MustCompile(`image\s+"([^"]*)"`)
+var lintStaticPattern = regexp.MustCompile(`(?:^|["'(\s=])/static/([^)\s"'?#]+)`)
+
+// lintProblem is a single problem found inside a presentation.
+type lintProblem struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (p lintProblem) String() string {
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+}
+
+// lintPresentation checks the Markdown file of the presentation for problems
+// that would otherwise only show up once the presentation is rendered.
+func lintPresentation(cfg *config.Config) ([]lintProblem, error) {
+	data, err := ioutil.ReadFile(cfg.MarkdownFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", cfg.MarkdownFile)
+	}
+	raw := string(data)
+	problems := make([]lintProblem, 0, 0)
+	report := func(line int, format string, args ...interface{}) {
+		problems = append(problems, lintProblem{File: cfg.MarkdownFile, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if _, err := loadTheme(cfg); err != nil {
+		report(0, "%s", err.Error())
+	}
+
+	funcs := newTemplateFuncs(cfg)
+	if cfg.MarkdownAsTemplate {
+		// Missing files are reported below for every loadCode, include and
+		// image call so they must not stop the rendering here.
+		funcs.Lenient = true
+		if _, err := buildContent(raw, cfg, funcs); err != nil {
+			if cerr, ok := err.(*contentError); ok {
+				problems = append(problems, lintProblem{File: cerr.File, Line: cerr.Line, Message: cerr.Message})
+			} else {
+				report(0, "%s", err.Error())
+			}
+		}
+	}
+
+	for idx, line := range strings.Split(raw, "\n") {
+		lineNumber := idx + 1
+		if cfg.MarkdownAsTemplate {
+			for _, m := range lintLoadCodePattern.FindAllStringSubmatch(line, -1) {
+				if _, err := os.Stat(funcs.resolvePath(m[1])); err != nil {
+					report(lineNumber, "loadCode: file %s not found", m[1])
+				}
+			}
+			for _, m := range lintLoadDataPattern.FindAllStringSubmatch(line, -1) {
+				if _, err := os.Stat(funcs.resolveDataPath(m[1])); err != nil {
+					report(lineNumber, "loadData: file %s not found", m[1])
+				}
+			}
+			for _, m := range lintIncludePattern.FindAllStringSubmatch(line, -1) {
+				if _, err := os.Stat(resolveIncludePath(cfg.MarkdownFile, m[1])); err != nil {
+					report(lineNumber, "include: file %s not found", m[1])
+				}
+			}
+			for _, m := range lintImagePattern.FindAllStringSubmatch(line, -1) {
+				// References starting with /static/ are checked below.
+				if cfg.StaticFolder == "" || strings.HasPrefix(m[1], "/static/") {
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(cfg.StaticFolder, filepath.FromSlash(m[1]))); err != nil {
+					report(lineNumber, "image: %s not found in %s", m[1], cfg.StaticFolder)
+				}
+			}
+			for _, m := range lintMarkLinesPattern.FindAllStringSubmatch(line, -1) {
+				_, errs := parseLineRangesWithErrors(m[1])
+				for _, err := range errs {
+					report(lineNumber, "markLines: %s", err.Error())
+				}
+			}
+			for _, m := range lintStepLinesPattern.FindAllStringSubmatch(line, -1) {
+				for _, step := range strings.Split(m[1], "|") {
+					ranges := strings.SplitN(step, ":", 2)[0]
+					_, errs := parseLineRangesWithErrors(strings.TrimSpace(ranges))
+					for _, err := range errs {
+						report(lineNumber, "stepLines: %s", err.Error())
+					}
+				}
+			}
+		}
+		for _, m := range lintStaticPattern.FindAllStringSubmatch(line, -1) {
+			if cfg.StaticFolder == "" {
+				report(lineNumber, "/static/%s is referenced but no static folder is configured", m[1])
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(cfg.StaticFolder, filepath.FromSlash(m[1]))); err != nil {
+				report(lineNumber, "/static/%s not found in %s", m[1], cfg.StaticFolder)
+			}
+		}
+	}
+
+	names := make(map[string]int)
+	for _, slide := range slides.Parse(raw) {
+		for _, p := range slide.Properties {
+			if p.Name != "name" {
+				continue
+			}
+			if first, found := names[p.Value]; found {
+				report(p.Line, "duplicate slide name %s (first used on line %d)", p.Value, first)
+				continue
+			}
+			names[p.Value] = p.Line
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+)
+
+func TestLintPresentation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-lint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	markdown := filepath.Join(dir, "slides.md")
+	content := `name: a
+
+{{ loadCode "missing.go" }}
+---
+name: a
+
+![img](/static/missing.png)
+{{ markLines "1-x" "" }}
+---
+{{ .Missing.Field }}
+{{ image "missing.jpg" }}
+{{ loadCode "demo.go" }}
+{{ include "` + filepath.Join(dir, "part.md") + `" }}
+`
+	require.NoError(t, ioutil.WriteFile(markdown, []byte(content), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "demo.go"), []byte("package demo"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "part.md"), []byte("Part"), 0644))
+	cfg := &config.Config{
+		MarkdownFile:         markdown,
+		BaseDir:              dir,
+		StaticFolder:         dir,
+		MarkdownAsTemplate:   true,
+		Theme:                "missing",
+		LeftActionDelimiter:  "{{",
+		RightActionDelimiter: "}}",
+	}
+	problems, err := lintPresentation(cfg)
+	require.NoError(t, err)
+	lines := make([]int, 0, len(problems))
+	for _, p := range problems {
+		lines = append(lines, p.Line)
+	}
+	require.Equal(t, []int{0, 3, 5, 7, 8, 10, 11}, lines)
+}
@@ -1,19 +1,23 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"html/template"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 	"github.com/zerok/remarked/internal/commandchain"
 	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/imaging"
+	"github.com/zerok/remarked/internal/remarkjs"
+	"github.com/zerok/remarked/internal/selfsigned"
 	"github.com/zerok/remarked/internal/token"
 )
 
@@ -21,6 +25,7 @@ const defaultRemarkJS = "https://remarkjs.com/downloads/remark-latest.min.js"
 const defaultMarkdownFile = "slides.md"
 const defaultConfigFile = "remarked.yml"
 const stylesheetMountPoint = "/style/_.css"
+const remarkJSMountPoint = "/js/remark.js"
 
 var commit, date, version string
 
@@ -29,43 +34,162 @@ type context struct {
 	RemarkJS      string
 	StyleSheetURL string
 	Title         string
+
+	// ThemeStylesheetURL is the URL of the theme's stylesheet which is
+	// included before the one of the presentation.
+	ThemeStylesheetURL string
+
+	// RemarkOptions are passed to remark.create.
+	RemarkOptions template.JS
 	IsGuide       bool
 	IsGuided      bool
 	Token         string
+	LiveReload    bool
+	BasePath      string
+
+	// If Runnable is set, code blocks included with playCode get a Run
+	// button.
+	Runnable bool
+
+	// RemarkJSIntegrity and StylesheetIntegrity are the Subresource
+	// Integrity hashes of remote versions of remark.js and the stylesheet.
+	RemarkJSIntegrity   string
+	StylesheetIntegrity string
+
+	// Nonce is set on all scripts so that the Content-Security-Policy
+	// allows them.
+	Nonce string
+
+	// InlineRemarkJS and the inline stylesheets are used instead of the URLs
+	// above if the presentation is exported into a single file.
+	InlineRemarkJS        template.JS
+	InlineStylesheet      template.CSS
+	InlineThemeStylesheet template.CSS
+
+	// play keeps the code blocks that can be run while rendering.
+	play *playground
+
+	// images resizes the images referenced while rendering.
+	images *imaging.Cache
+}
+
+// overrides contains all settings that were set through command-line flags
+// and take precedence over the configuration file.
+type overrides struct {
+	MarkdownFile  string
+	TemplateFile  string
+	Title         string
+	RemarkJS      string
+	Stylesheet    string
+	Theme         string
+	StaticFolder  string
+	TLSCert       string
+	TLSKey        string
+	TLSSelfSigned bool
+
+	// remark contains a layer for every option of remark.create set with
+	// --remark.
+	remark []config.Layer
+}
+
+// layers returns a configuration layer for every flag that was set.
+func (o *overrides) layers() []config.Layer {
+	var layers []config.Layer
+	add := func(flag string, key string, value interface{}) {
+		layers = append(layers, config.Layer{Source: "--" + flag, Values: map[string]interface{}{key: value}})
+	}
+	if o.MarkdownFile != "" {
+		add("markdown-file", "markdownFile", o.MarkdownFile)
+	}
+	if o.TemplateFile != "" {
+		add("template-file", "templateFile", o.TemplateFile)
+	}
+	if o.Title != "" {
+		add("title", "title", o.Title)
+	}
+	if o.RemarkJS != "" {
+		add("remarkjs", "remarkJS", o.RemarkJS)
+	}
+	if o.Stylesheet != "" {
+		add("stylesheet", "stylesheet", o.Stylesheet)
+	}
+	if o.Theme != "" {
+		add("theme", "theme", o.Theme)
+	}
+	if o.StaticFolder != "" {
+		add("static-folder", "staticFolder", o.StaticFolder)
+	}
+	if o.TLSCert != "" {
+		add("tls-cert", "tlsCert", o.TLSCert)
+	}
+	if o.TLSKey != "" {
+		add("tls-key", "tlsKey", o.TLSKey)
+	}
+	if o.TLSSelfSigned {
+		add("tls-self-signed", "tlsSelfSigned", true)
+	}
+	return append(layers, o.remark...)
+}
+
+// parseRemark parses the options of remark.create set on the command-line
+// as KEY=VALUE.
+func (o *overrides) parseRemark(values []string) error {
+	for _, value := range values {
+		option, err := config.ParseRemarkOption(value)
+		if err != nil {
+			return err
+		}
+		o.remark = append(o.remark, config.Layer{Source: "--remark " + value, Values: map[string]interface{}{"remark": option}})
+	}
+	return nil
 }
 
 func main() {
 	var configPath string
-	var markdownFile string
-	var templateFile string
 	var addr string
 	var verbose bool
-	var remarkJS string
-	var styleSheet string
-	var title string
 	var err error
 	var guide bool
-	var staticFolder string
 	var tkn string
 	var initialize bool
 	var showVersion bool
+	var export exportOptions
+	var remarkFlags []string
+	var flags overrides
+	var decksFolder string
 	pflag.StringVar(&configPath, "config", "remarked.yml", "Path to a configuration file")
-	pflag.StringVar(&title, "title", "", "Presentation title")
-	pflag.StringVar(&markdownFile, "markdown-file", "", "Path to a markdown file")
-	pflag.StringVar(&templateFile, "template-file", "", "Path to a template file to override the default HTML output")
-	pflag.StringVar(&remarkJS, "remarkjs", "", "URL or filepath of the remark.js file")
+	pflag.StringVar(&flags.Title, "title", "", "Presentation title")
+	pflag.StringVar(&flags.MarkdownFile, "markdown-file", "", "Path to a markdown file")
+	pflag.StringVar(&flags.TemplateFile, "template-file", "", "Path to a template file to override the default HTML output")
+	pflag.StringVar(&flags.RemarkJS, "remarkjs", "", "URL or filepath of the remark.js file")
 	pflag.StringVar(&addr, "http-addr", "localhost:8000", "Start HTTP server on this address")
-	pflag.StringVar(&styleSheet, "stylesheet", "", "URL or filepath of a stylesheet")
-	pflag.StringVar(&staticFolder, "static-folder", "", "Path to a folder that should be served through /static")
+	pflag.StringVar(&flags.Stylesheet, "stylesheet", "", "URL or filepath of a stylesheet")
+	pflag.StringVar(&flags.Theme, "theme", "", "Name of a theme or path to a theme folder")
+	pflag.StringArrayVar(&remarkFlags, "remark", nil, "Option passed to remark.create as KEY=VALUE (e.g. ratio=16:9 or navigation.scroll=false)")
+	pflag.StringVar(&flags.StaticFolder, "static-folder", "", "Path to a folder that should be served through /static")
+	pflag.StringVar(&flags.TLSCert, "tls-cert", "", "Path to a certificate file for serving through HTTPS")
+	pflag.StringVar(&flags.TLSKey, "tls-key", "", "Path to the key file matching --tls-cert")
+	pflag.BoolVar(&flags.TLSSelfSigned, "tls-self-signed", false, "Serve through HTTPS using a generated self-signed certificate")
 	pflag.BoolVar(&verbose, "verbose", false, "Verbose logging")
 	pflag.BoolVar(&guide, "guide", false, "Allow guided mode")
 	pflag.StringVar(&tkn, "guide-token", "", "Token required for acting as guide")
+	pflag.StringVar(&decksFolder, "decks", "", "Serve all presentations found in subfolders of this folder")
 	pflag.BoolVar(&initialize, "init", false, "Initialize a remarked project in the current folder")
 	pflag.BoolVar(&showVersion, "version", false, "Show version information")
+	pflag.StringVar(&export.OutputFolder, "output", "dist", "Folder the export command writes the presentation into")
+	pflag.StringVar(&export.SingleFile, "single-file", "", "Let the export command write a single self-contained HTML file instead")
+	pflag.BoolVar(&export.Watch, "watch", false, "Reload the presentation or rebuild the export whenever one of its input files changes")
 	pflag.Parse()
 
+	command := pflag.Arg(0)
+
 	if showVersion {
-		fmt.Printf("Version: %s\nCommit: %s\nDate: %s\n", version, commit, date)
+		fmt.Printf("Version: %s\nCommit: %s\nDate: %s\nRemarkJS: %s\n", version, commit, date, embeddedRemarkJSVersion())
+		os.Exit(0)
+	}
+
+	if command == "remarkjs" {
+		fmt.Println(embeddedRemarkJSVersion())
 		os.Exit(0)
 	}
 
@@ -74,6 +198,17 @@ func main() {
 		log.SetLevel(logrus.DebugLevel)
 	}
 
+	if command == "theme" {
+		if err := runTheme(pflag.Args()[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := flags.parseRemark(remarkFlags); err != nil {
+		log.WithError(err).Fatal("Invalid --remark option")
+	}
+
 	if initialize {
 		if err := doInit(log); err != nil {
 			log.WithError(err).Fatal("Failed to initialize a new remarked project in the current folder")
@@ -81,150 +216,295 @@ func main() {
 		return
 	}
 
-	cfg, err := config.LoadFromPath(configPath)
-	if err != nil {
-		log.WithError(err).Fatalf("Failed to read config file: %s", configPath)
-	}
-	if markdownFile != "" {
-		cfg.MarkdownFile = markdownFile
-	}
-	if templateFile != "" {
-		cfg.TemplateFile = templateFile
-	}
-	if title != "" {
-		cfg.Title = title
-	}
-	if remarkJS != "" {
-		cfg.RemarkJS = remarkJS
-	}
-	if cfg.RemarkJS == "" {
-		cfg.RemarkJS = defaultRemarkJS
+	if command != "" && command != "export" && command != "lint" && command != "remarkjs" && command != "config" {
+		log.Fatalf("Unknown command: %s", command)
 	}
-	if cfg.MarkdownFile == "" {
-		log.Infof("No markdown file specified. Using %s", defaultMarkdownFile)
-		cfg.MarkdownFile = defaultMarkdownFile
+
+	if decksFolder != "" {
+		if command != "" {
+			log.Fatalf("%s cannot be combined with --decks", command)
+		}
+		handler, err := serveDecks(decksFolder, guide, export.Watch, log)
+		if err != nil {
+			log.WithError(err).Fatalf("Failed to load presentations from %s", decksFolder)
+		}
+		tlsSettings, _, err := config.Load(append([]config.Layer{config.Defaults()}, flags.layers()...)...)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid command-line flags")
+		}
+		listen(addr, handler, tlsSettings, log)
+		return
 	}
-	if styleSheet != "" {
-		cfg.Stylesheet = styleSheet
+
+	cfg, sources, err := loadConfig(configPath, &flags, "", log)
+	if err != nil {
+		log.WithError(err).Fatalf("Failed to read config file: %s", configPath)
 	}
 	if guide {
+		sources["token"] = "--guide-token"
 		if tkn == "" {
 			tkn = token.Generate()
+			sources["token"] = "generated"
 		}
 		cfg.Token = tkn
 	}
 
-	if staticFolder != "" {
-		cfg.StaticFolder = staticFolder
-	}
-
-	if guide {
-		log.Infof("Starting guide mode with this token:\n\n  %s\n\n", cfg.Token)
+	if command == "config" {
+		if err := runConfig(pflag.Args()[1:], cfg, sources, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	if cfg.Title == "" {
-		log.Info("No title specified. Using the name of the containing folder instead.")
-		cfg.Title, err = getFolderName(markdownFile)
+	if command == "lint" {
+		problems, err := lintPresentation(cfg)
 		if err != nil {
-			log.WithError(err).Fatalf("Failed to determine name of %s's parent folder", markdownFile)
+			log.WithError(err).Fatal("Failed to lint presentation")
+		}
+		for _, p := range problems {
+			fmt.Println(p)
 		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		return
 	}
 
-	srv := http.Server{
-		ReadTimeout:  time.Second * 2,
-		WriteTimeout: time.Second * 2,
+	if command == "export" {
+		if err := runExport(cfg, export, log); err != nil {
+			log.WithError(err).Fatalf("Failed to export presentation to %s", export.target())
+		}
+		return
 	}
-	srv.Addr = addr
-	mux := http.NewServeMux()
-	srv.Handler = mux
-
-	funcs := templateFuncs{}
 
-	localStylesheet, ok := isLocalStylesheet(cfg.Stylesheet)
-	if ok {
-		mux.HandleFunc(stylesheetMountPoint, func(w http.ResponseWriter, r *http.Request) {
-			http.ServeFile(w, r, localStylesheet)
-		})
-		cfg.FinalStylesheet = stylesheetMountPoint
-	} else if cfg.Stylesheet != "" {
-		cfg.FinalStylesheet = cfg.Stylesheet
+	if guide {
+		log.Infof("Starting guide mode with this token:\n\n  %s\n\n", cfg.Token)
 	}
 
-	if guide {
-		hub := commandchain.Hub{Log: log}
-		mux.HandleFunc("/guide/login", guideLoginHandler(cfg, log))
-		mux.HandleFunc("/guide", token.Require(cfg.Token, "/guide/login", guideHandler(cfg, log)))
-		mux.HandleFunc("/ws/guide", guideWebsocketHandler(cfg, &hub, log))
-		mux.HandleFunc("/ws/guided", guidedWebsocketHandler(cfg, &hub, log))
+	d := &deck{
+		ConfigPath: configPath,
+		Flags:      &flags,
+		Token:      tkn,
+		Hub:        &commandchain.Hub{Log: log},
+		Guide:      guide,
+		Log:        log,
 	}
+	for _, problem := range cfg.Validate() {
+		log.Warn(problem)
+	}
+	if export.Watch {
+		d.Reload = &liveReload{Hub: d.Hub, Log: log, Prepare: d.Warm}
+		go d.Reload.Run()
+	}
+	if err := d.Update(cfg); err != nil {
+		log.WithError(err).Fatal("Failed to set up routes")
+	}
+	go d.watch()
+	log.Debugf("Final configuration: %s", cfg)
+	listen(addr, d, cfg, log)
+}
 
-	if cfg.StaticFolder != "" {
-		fullStaticFolder, err := filepath.Abs(cfg.StaticFolder)
-		if err != nil {
-			log.WithError(err).Fatalf("Failed to resolve absolute path to static folder %s", cfg.StaticFolder)
-		}
-		log.Debugf("Serving static files from %s", fullStaticFolder)
-		mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(fullStaticFolder))))
+// listen starts the HTTP server on the given address. If the configuration
+// contains TLS settings, the server is started with HTTPS instead.
+func listen(addr string, handler http.Handler, cfg *config.Config, log *logrus.Logger) {
+	var err error
+	srv := http.Server{
+		ReadTimeout:  time.Second * 2,
+		WriteTimeout: time.Second * 2,
+		Addr:         addr,
+		Handler:      handler,
 	}
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		tmpl, err := loadOutputTemplate(cfg.TemplateFile)
+	if cfg.TLSSelfSigned {
+		cfg.TLSCert, cfg.TLSKey, err = selfSignedCertificate(addr)
 		if err != nil {
-			log.WithError(err).Errorf("Failed to parse template ")
-			http.Error(w, "Failed to parse template file", http.StatusInternalServerError)
-			return
+			log.WithError(err).Fatal("Failed to generate self-signed certificate")
 		}
-		data, err := ioutil.ReadFile(cfg.MarkdownFile)
+		fingerprint, err := selfsigned.Fingerprint(cfg.TLSCert)
 		if err != nil {
-			log.WithError(err).Errorf("Failed to read %s", cfg.MarkdownFile)
-			http.Error(w, "Failed to read file", http.StatusInternalServerError)
-			return
-		}
-
-		ctx := &context{
-			RemarkJS:      cfg.RemarkJS,
-			StyleSheetURL: cfg.FinalStylesheet,
-			Title:         cfg.Title,
-			IsGuided:      guide,
+			log.WithError(err).Fatalf("Failed to read %s", cfg.TLSCert)
 		}
+		log.Infof("Using self-signed certificate with this SHA-256 fingerprint:\n\n  %s\n\n", fingerprint)
+	}
 
-		rawData := string(data)
-		content, err := buildContent(rawData, cfg, funcs.FuncMap())
-		if err != nil {
-			log.WithError(err).Error("Failed to compile content")
-			http.Error(w, "Failed to compile output", http.StatusInternalServerError)
-			return
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		log.Infof("Starting server on https://%s", addr)
+		if err := srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey); err != nil {
+			log.WithError(err).Fatalf("Failed to start server on %s", addr)
 		}
-		ctx.Source = content
-		tmpl.Execute(w, ctx)
-	})
-
+		return
+	}
 	log.Infof("Starting server on %s", addr)
-	log.Debugf("Final configuration: %s", cfg)
 	if err := srv.ListenAndServe(); err != nil {
 		log.WithError(err).Fatalf("Failed to start server on %s", addr)
 	}
 }
 
-func buildContent(rawContent string, cfg *config.Config, fmap template.FuncMap) (string, error) {
-	if cfg.MarkdownAsTemplate {
-		var content bytes.Buffer
-		contentTmpl, err := template.New("content").
-			Funcs(fmap).
-			Delims(cfg.LeftActionDelimiter, cfg.RightActionDelimiter).
-			Parse(rawContent)
+// userConfigFile returns the path of the configuration file of the current
+// user.
+var userConfigFile = config.UserFile
+
+// loadConfig reads the configuration from the user's configuration file, the
+// configuration file at the given path, REMARKED_* environment variables and
+// the command-line overrides (in increasing precedence) and fills in
+// defaults for everything that is still missing. If baseDir is set, all
+// relative paths are resolved against it. Relative paths inside the user's
+// configuration file are relative to its folder.
+func loadConfig(path string, flags *overrides, baseDir string, log *logrus.Logger) (*config.Config, config.Sources, error) {
+	layers := []config.Layer{config.Defaults()}
+	if userFile := userConfigFile(); userFile != "" {
+		layer, err := config.FileLayer(userFile)
+		if err == nil {
+			layer.Dir = filepath.Dir(userFile)
+			layers = append(layers, layer)
+		} else if !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+	layer, err := config.FileLayer(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	layers = append(layers, layer)
+	env, unknownEnv, err := config.EnvLayers(os.Environ())
+	if err != nil {
+		return nil, nil, err
+	}
+	layers = append(layers, env...)
+	layers = append(layers, flags.layers()...)
+	cfg, sources, err := config.Load(layers...)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range unknownEnv {
+		if !isExposedEnv(cfg, name) {
+			log.Warn(config.DescribeUnknownEnv(name))
+		}
+	}
+	if cfg.RemarkJS == "" && !remarkjs.Available() {
+		log.Warnf("No remark.js embedded. Using %s", defaultRemarkJS)
+		cfg.RemarkJS = defaultRemarkJS
+		sources["remarkJS"] = config.DefaultSource
+	}
+	if cfg.MarkdownFile == "" {
+		log.Infof("No markdown file specified. Using %s", defaultMarkdownFile)
+		cfg.MarkdownFile = defaultMarkdownFile
+		sources["markdownFile"] = config.DefaultSource
+	}
+	if baseDir != "" {
+		cfg.ResolvePaths(baseDir)
+	}
+	if cfg.Title == "" {
+		log.Info("No title specified. Using the name of the containing folder instead.")
+		cfg.Title, err = getFolderName(cfg.MarkdownFile)
 		if err != nil {
-			return "", fmt.Errorf("Failed to parse file: %s", err.Error())
+			return nil, nil, errors.Wrapf(err, "failed to determine name of %s's parent folder", cfg.MarkdownFile)
 		}
-		if err := contentTmpl.Execute(&content, context{}); err != nil {
-			return "", fmt.Errorf("Failed to render template: %s", err.Error())
+		sources["title"] = config.DefaultSource
+	}
+	return cfg, sources, nil
+}
+
+// isExposedEnv reports whether the environment variable with the given name
+// is made available to the Markdown file.
+func isExposedEnv(cfg *config.Config, name string) bool {
+	for _, exposed := range cfg.Env {
+		if exposed == name {
+			return true
 		}
-		return content.String(), nil
 	}
-	return rawContent, nil
+	return false
+}
+
+// selfSignedCertificate returns a certificate and key file for a self-signed
+// certificate that covers the given listen address as well as all local
+// network addresses. The certificate is cached in the user's cache folder.
+func selfSignedCertificate(addr string) (string, string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	hosts, err := selfsigned.LANHosts()
+	if err != nil {
+		return "", "", err
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil && host != "" {
+		hosts = append(hosts, host)
+	}
+	return selfsigned.LoadOrGenerate(filepath.Join(cacheDir, "remarked", "tls"), hosts)
+}
+
+// contentData is the data available inside a Markdown file that is used as
+// template.
+type contentData struct {
+	// Data is the data section of the configuration file.
+	Data map[string]interface{}
+
+	// Config is the effective configuration of the presentation without
+	// the guide token, as everything in it can end up on the slides.
+	Config *config.Config
+
+	// Now is the time the presentation was rendered at.
+	Now time.Time
+
+	// Version is the version of remarked.
+	Version string
+
+	// Env contains the environment variables listed in the configuration.
+	Env map[string]string
+
+	// Args contains the arguments passed to include.
+	Args map[string]interface{}
+}
+
+func newContentData(cfg *config.Config) *contentData {
+	public := *cfg
+	public.Token = ""
+	data := &contentData{
+		Data:    cfg.Data,
+		Config:  &public,
+		Now:     time.Now(),
+		Version: version,
+		Env:     make(map[string]string),
+		Args:    make(map[string]interface{}),
+	}
+	if data.Data == nil {
+		data.Data = make(map[string]interface{})
+	}
+	for _, name := range cfg.Env {
+		data.Env[name] = os.Getenv(name)
+	}
+	return data
 }
 
-func isLocalStylesheet(u string) (string, bool) {
+// buildContent renders the given Markdown file content as template if this
+// is enabled in the configuration and replaces diagram blocks with SVG.
+// Template errors are reported as *contentError.
+func buildContent(rawContent string, cfg *config.Config, funcs *templateFuncs) (string, error) {
+	if !cfg.MarkdownAsTemplate {
+		return renderDiagrams(rawContent)
+	}
+	name := "content"
+	if cfg.MarkdownFile != "" {
+		name = filepath.Clean(cfg.MarkdownFile)
+	}
+	funcs.cfg = cfg
+	funcs.data = newContentData(cfg)
+	funcs.includes = []string{name}
+	content, err := funcs.render(name, rawContent, funcs.data)
+	if err != nil {
+		return "", newContentError(err)
+	}
+	content, err = funcs.expandSteps(content)
+	if err != nil {
+		return "", newContentError(err)
+	}
+	return renderDiagrams(content)
+}
+
+// isLocalFile checks if the given path or URL refers to a file on the local
+// filesystem.
+func isLocalFile(u string) (string, bool) {
 	if u == "" {
 		return "", false
 	}
@@ -234,6 +514,13 @@ func isLocalStylesheet(u string) (string, bool) {
 	return u, true
 }
 
+func embeddedRemarkJSVersion() string {
+	if !remarkjs.Available() {
+		return "not embedded"
+	}
+	return remarkjs.Version()
+}
+
 func getFolderName(p string) (string, error) {
 	abs, err := filepath.Abs(p)
 	if err != nil {
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/zerok/remarked/internal/runner"
+)
+
+// defaultPlayMaxOutput limits the output of code run from the browser if the
+// runner doesn't set a limit itself.
+const defaultPlayMaxOutput = 64 * 1024
+
+// playground keeps the code blocks that were included with playCode. The
+// browser only sends the ID of a block so that nothing but the code inside
+// the presentation can be run.
+type playground struct {
+	lock     sync.Mutex
+	snippets map[string]runner.Spec
+}
+
+func (p *playground) register(id string, spec runner.Spec) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.snippets == nil {
+		p.snippets = make(map[string]runner.Spec)
+	}
+	p.snippets[id] = spec
+}
+
+func (p *playground) get(id string) (runner.Spec, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	spec, found := p.snippets[id]
+	return spec, found
+}
+
+// PlayCode includes the file at the given path like loadCode (as fenced code
+// block unless another mode is given) and marks it as runnable with the
+// given runner. If this is enabled in the configuration, a Run button is
+// added to it that runs the whole file.
+func (f *templateFuncs) PlayCode(name string, path string, options ...string) (template.HTML, error) {
+	if f.cfg == nil {
+		return "", fmt.Errorf("playCode can only be used inside Markdown templates")
+	}
+	cfg, found := f.cfg.Runners[name]
+	if !found {
+		return "", fmt.Errorf("playCode: no runner %s configured", name)
+	}
+	fenced := true
+	for _, option := range options {
+		if strings.HasPrefix(option, "mode=") || strings.HasPrefix(option, "lang=") {
+			fenced = false
+		}
+	}
+	if fenced {
+		options = append(options, "mode="+codeModeFence)
+	}
+	content, err := f.LoadCode(path, options...)
+	if err != nil || content == "" {
+		return content, err
+	}
+	file, err := filepath.Abs(f.resolvePath(path))
+	if err != nil {
+		return "", err
+	}
+	code, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	spec := runnerSpec(cfg)
+	spec.File = file
+	if spec.Dir == "" {
+		spec.Dir = filepath.Dir(file)
+	}
+	if spec.MaxOutput == 0 {
+		spec.MaxOutput = defaultPlayMaxOutput
+	}
+	id := runner.Key(spec, code)[:16]
+	f.Play.register(id, spec)
+	return template.HTML(fmt.Sprintf(".runnable.run-%s[\n%s\n]", id, strings.TrimSuffix(string(content), "\n"))), nil
+}
+
+// runMessage is sent to the browser while code is running. Output messages
+// contain a chunk of the program's output and the final exit message the
+// reason for a failure, if any.
+type runMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+}
+
+// runOutput sends everything written to it as output message.
+type runOutput struct {
+	conn *websocket.Conn
+}
+
+func (o *runOutput) Write(p []byte) (int, error) {
+	if err := o.conn.WriteJSON(runMessage{Type: "output", Data: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// runWebsocketHandler runs the code block whose ID is sent as first message
+// and streams its output back. The program is killed if the connection is
+// closed.
+func runWebsocketHandler(play *playground, log *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var upgrader = websocket.Upgrader{
+			ReadBufferSize:   1024,
+			WriteBufferSize:  1024,
+			HandshakeTimeout: time.Second * 2,
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.WithError(err).Error("Failed to upgrade connection")
+			http.Error(w, "Failed to upgrade connection", http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		var request struct {
+			ID string `json:"id"`
+		}
+		if err := conn.ReadJSON(&request); err != nil {
+			log.WithError(err).Debug("Failed to read run request")
+			return
+		}
+		defer conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		spec, found := play.get(request.ID)
+		if !found {
+			conn.WriteJSON(runMessage{Type: "exit", Data: "unknown code block"})
+			return
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+		log.Infof("Running %s", strings.Join(spec.Args(), " "))
+		result, err := runner.Run(spec, &runOutput{conn: conn}, done)
+		exit := runMessage{Type: "exit"}
+		if err != nil {
+			log.WithError(err).Errorf("Failed to run %s", spec.File)
+			exit.Data = "failed to run: " + err.Error()
+		} else {
+			exit.Data = result.Failure()
+		}
+		conn.WriteJSON(exit)
+	}
+}
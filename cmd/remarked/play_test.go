@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+)
+
+func TestPlayCode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-play")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hello.sh"), []byte("echo hello\n"), 0644))
+	cfg := &config.Config{
+		Runners: map[string]config.Runner{
+			"sh": {Command: []string{"sh"}},
+		},
+	}
+	play := &playground{}
+	funcs := &templateFuncs{BaseDir: dir, Play: play, cfg: cfg}
+
+	content, err := funcs.PlayCode("sh", "hello.sh")
+	require.NoError(t, err)
+	m := regexp.MustCompile(`^\.runnable\.run-([0-9a-f]{16})\[\n` + "```bash\necho hello\n```" + `\n\]$`).FindStringSubmatch(string(content))
+	require.NotNil(t, m, string(content))
+	_, found := play.get(m[1])
+	require.True(t, found)
+
+	_, err = funcs.PlayCode("ruby", "hello.sh")
+	require.Error(t, err)
+
+	server := httptest.NewServer(runWebsocketHandler(play, logrus.New()))
+	defer server.Close()
+	run := func(id string) []runMessage {
+		conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(server.URL, "http://", "ws://", 1), nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, conn.WriteJSON(map[string]string{"id": id}))
+		var messages []runMessage
+		for {
+			var msg runMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return messages
+			}
+			messages = append(messages, msg)
+		}
+	}
+	require.Equal(t, []runMessage{{Type: "output", Data: "hello\n"}, {Type: "exit"}}, run(m[1]))
+	require.Equal(t, []runMessage{{Type: "exit", Data: "unknown code block"}}, run("unknown"))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/zerok/remarked/internal/commandchain"
+	"github.com/zerok/remarked/internal/watcher"
+)
+
+// liveReload watches all the input files of a presentation and asks every
+// connected browser to reload once one of them has changed. All methods can
+// be called on a nil liveReload in which case they do nothing.
+type liveReload struct {
+	Hub *commandchain.Hub
+	Log *logrus.Logger
+
+	// Prepare is called after a change before the browsers are asked to
+	// reload, e.g. to render the presentation ahead of their requests.
+	Prepare func()
+
+	watcher watcher.Watcher
+}
+
+// Watch adds the given files to the list of watched files.
+func (l *liveReload) Watch(paths ...string) {
+	if l == nil {
+		return
+	}
+	l.watcher.Add(paths...)
+}
+
+// Run blocks and broadcasts a reload command through the hub whenever a
+// change was detected.
+func (l *liveReload) Run() {
+	if l == nil {
+		return
+	}
+	l.watcher.Log = l.Log
+	l.watcher.Run(nil, func(changed []string) {
+		l.Log.Infof("Reloading clients after changes to %s", strings.Join(changed, ", "))
+		if l.Prepare != nil {
+			l.Prepare()
+		}
+		l.Notify()
+	})
+}
+
+// Notify asks all connected browsers to reload the presentation.
+func (l *liveReload) Notify() {
+	if l == nil {
+		return
+	}
+	l.Hub.BroadcastCommand(commandchain.Command{Type: commandchain.ReloadCommand}, nil)
+}
+
+func reloadWebsocketHandler(hub *commandchain.Hub, log *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var upgrader = websocket.Upgrader{
+			ReadBufferSize:   1024,
+			WriteBufferSize:  1024,
+			HandshakeTimeout: time.Second * 2,
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.WithError(err).Error("Failed to upgrade connection")
+			http.Error(w, "Failed to upgrade connection", http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		recv := &commandchain.Receiver{
+			Conn: conn,
+			Log:  log,
+			Accept: func(cmd commandchain.Command) bool {
+				return cmd.Type == commandchain.ReloadCommand
+			},
+		}
+		hub.RegisterReceiver(recv)
+		defer hub.UnregisterReceiver(recv)
+		if err := recv.Handle(r.Context()); err != nil {
+			log.WithError(err).Debug("Reload receiver exited")
+		}
+	}
+}
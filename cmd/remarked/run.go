@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/runner"
+)
+
+// RunCode runs the file at the given path with the runner configured under
+// the given name and returns its output as fenced code block. The following
+// options are supported:
+//
+//	inline          source is the code itself instead of a path
+//	timeout=DUR     overrides the timeout of the runner
+//	dir=PATH        overrides the working directory of the runner
+//
+// Failures are rendered into the output instead of aborting the render.
+// Results are cached on disk until the source or the runner changes.
+func (f *templateFuncs) RunCode(name string, source string, options ...string) (template.HTML, error) {
+	if f.Lenient {
+		return "", nil
+	}
+	output, err := f.runCode(name, source, options)
+	if err != nil {
+		output = fmt.Sprintf("runCode: %s", err.Error())
+	}
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	fence := codeFence(lines)
+	return template.HTML(fence + "\n" + strings.Join(lines, "\n") + "\n" + fence), nil
+}
+
+func (f *templateFuncs) runCode(name string, source string, options []string) (string, error) {
+	if f.cfg == nil {
+		return "", fmt.Errorf("runCode can only be used inside Markdown templates")
+	}
+	cfg, found := f.cfg.Runners[name]
+	if !found {
+		return "", fmt.Errorf("no runner %s configured", name)
+	}
+	spec := runnerSpec(cfg)
+	inline := false
+	for _, option := range options {
+		elems := strings.SplitN(option, "=", 2)
+		switch {
+		case option == "inline":
+			inline = true
+		case elems[0] == "timeout" && len(elems) == 2:
+			timeout, err := time.ParseDuration(elems[1])
+			if err != nil {
+				return "", fmt.Errorf("invalid timeout %s", elems[1])
+			}
+			spec.Timeout = timeout
+		case elems[0] == "dir" && len(elems) == 2:
+			spec.Dir = f.resolvePath(elems[1])
+		default:
+			return "", fmt.Errorf("unknown option %s", option)
+		}
+	}
+
+	var code []byte
+	if inline {
+		code = []byte(source)
+		spec.File = "snippet" + cfg.Extension
+		if spec.Dir == "" {
+			spec.Dir = f.DataDir
+		}
+	} else {
+		path, err := filepath.Abs(f.resolvePath(source))
+		if err != nil {
+			return "", err
+		}
+		f.addFile(path)
+		code, err = ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		spec.File = path
+		if spec.Dir == "" {
+			spec.Dir = filepath.Dir(path)
+		}
+	}
+
+	key := runner.Key(spec, code)
+	if entry, found := f.RunCache.Get(key); found {
+		return formatRun(entry.Output, &entry.Result), nil
+	}
+	var output bytes.Buffer
+	var result *runner.Result
+	var err error
+	if inline {
+		result, err = runSnippet(spec, code, &output, nil)
+	} else {
+		result, err = runner.Run(spec, &output, nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %s", strings.Join(spec.Args(), " "), err.Error())
+	}
+	if !result.TimedOut {
+		f.RunCache.Put(key, &runner.Entry{Output: output.String(), Result: *result})
+	}
+	return formatRun(output.String(), result), nil
+}
+
+// runnerSpec converts a runner from the configuration into a spec.
+func runnerSpec(cfg config.Runner) runner.Spec {
+	return runner.Spec{
+		Command:   cfg.Command,
+		Dir:       cfg.Dir,
+		Timeout:   cfg.Timeout,
+		CPULimit:  cfg.CPULimit,
+		MaxOutput: cfg.MaxOutput,
+	}
+}
+
+// runSnippet writes the code into a temporary file named like spec.File and
+// runs it.
+func runSnippet(spec runner.Spec, code []byte, output io.Writer, done <-chan struct{}) (*runner.Result, error) {
+	tmpDir, err := ioutil.TempDir("", "remarked-run")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	spec.File = filepath.Join(tmpDir, filepath.Base(spec.File))
+	if err := ioutil.WriteFile(spec.File, code, 0600); err != nil {
+		return nil, err
+	}
+	return runner.Run(spec, output, done)
+}
+
+// formatRun appends the reason of a failed run to its output.
+func formatRun(output string, result *runner.Result) string {
+	if failure := result.Failure(); failure != "" {
+		if output != "" && !strings.HasSuffix(output, "\n") {
+			output += "\n"
+		}
+		output += "(" + failure + ")"
+	}
+	return output
+}
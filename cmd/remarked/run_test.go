@@ -0,0 +1,86 @@
+package main
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/runner"
+)
+
+func TestRunCode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-run")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "hello.sh")
+	require.NoError(t, ioutil.WriteFile(script, []byte("echo hello from $(basename $(pwd))\n"), 0644))
+	cfg := &config.Config{
+		Runners: map[string]config.Runner{
+			"sh": {Command: []string{"sh"}, Extension: ".sh"},
+		},
+	}
+	funcs := &templateFuncs{
+		BaseDir:  dir,
+		DataDir:  dir,
+		RunCache: &runner.Cache{Dir: filepath.Join(dir, "cache")},
+		cfg:      cfg,
+	}
+
+	output, err := funcs.RunCode("sh", "hello.sh")
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("```\nhello from "+filepath.Base(dir)+"\n```"), output)
+	require.Equal(t, []string{script}, funcs.Files())
+
+	// A changed script is not served from the cache.
+	require.NoError(t, ioutil.WriteFile(script, []byte("echo changed\nexit 2\n"), 0644))
+	output, err = funcs.RunCode("sh", "hello.sh")
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("```\nchanged\n(exit status 2)\n```"), output)
+
+	// Cached results are used without running the script again.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "marker"), nil, 0644))
+	output, err = funcs.RunCode("sh", "rm marker; echo inline", "inline")
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("```\ninline\n```"), output)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "marker"), nil, 0644))
+	output, err = funcs.RunCode("sh", "rm marker; echo inline", "inline")
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("```\ninline\n```"), output)
+	_, err = os.Stat(filepath.Join(dir, "marker"))
+	require.NoError(t, err)
+
+	output, err = funcs.RunCode("sh", "sleep 5", "inline", "timeout=50ms")
+	require.NoError(t, err)
+	require.Contains(t, string(output), "(timed out after")
+
+	output, err = funcs.RunCode("ruby", "hello.rb")
+	require.NoError(t, err)
+	require.Equal(t, template.HTML("```\nrunCode: no runner ruby configured\n```"), output)
+}
+
+func TestRunCodeCacheDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-run")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cfg := &config.Config{
+		BaseDir:              dir,
+		MarkdownAsTemplate:   true,
+		LeftActionDelimiter:  "{{",
+		RightActionDelimiter: "}}",
+		RunCacheDir:          filepath.Join(dir, "cache"),
+		Runners: map[string]config.Runner{
+			"sh": {Command: []string{"sh"}, Extension: ".sh"},
+		},
+	}
+
+	content, err := buildContent(`{{ runCode "sh" "echo cached" "inline" }}`, cfg, newTemplateFuncs(cfg))
+	require.NoError(t, err)
+	require.Equal(t, "```\ncached\n```", content)
+	entries, err := ioutil.ReadDir(cfg.RunCacheDir)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/integrity"
+)
+
+const contentSecurityPolicyHeader = "Content-Security-Policy"
+
+// defaultSecurityHeaders are sent with every response unless they are
+// overridden in the configuration. Strict-Transport-Security is left out
+// on purpose as it would stick to the self-signed certificates of local
+// network addresses.
+var defaultSecurityHeaders = map[string]string{
+	"X-Content-Type-Options":     "nosniff",
+	"X-Frame-Options":            "SAMEORIGIN",
+	"Referrer-Policy":            "same-origin",
+	"Cross-Origin-Opener-Policy": "same-origin",
+}
+
+// noncePlaceholder is rendered into pages instead of an actual nonce so
+// that the rendered page can be cached. It is replaced by a new nonce for
+// every response.
+var noncePlaceholder = "remarked-nonce-" + newNonce()
+
+var noncePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{22}$`)
+
+// newNonce returns 128 random bits as used for the nonce of the scripts
+// inside a page.
+func newNonce() string {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// security sets the response headers of a deck.
+type security struct {
+	// policy is the Content-Security-Policy with {nonce} as placeholder
+	// for the nonce of the response. If it is empty, no policy is sent.
+	policy  string
+	headers map[string]string
+}
+
+// newSecurity creates the headers for the given configuration. The URLs
+// remark.js and the stylesheet are served from have to be resolved
+// already. Custom templates only get a Content-Security-Policy if it is
+// configured explicitly as their inline scripts most likely don't carry the
+// nonce.
+func newSecurity(cfg *config.Config) *security {
+	s := &security{
+		headers: make(map[string]string, len(defaultSecurityHeaders)),
+	}
+	if cfg.TemplateFile == "" {
+		s.policy = contentSecurityPolicy(cfg)
+	}
+	for name, value := range defaultSecurityHeaders {
+		s.headers[name] = value
+	}
+	for name, value := range cfg.Security.Headers {
+		name = http.CanonicalHeaderKey(name)
+		switch {
+		case name == contentSecurityPolicyHeader:
+			s.policy = value
+		case value == "":
+			delete(s.headers, name)
+		default:
+			s.headers[name] = value
+		}
+	}
+	return s
+}
+
+// contentSecurityPolicy returns the default policy. Scripts have to come
+// from remarked itself (or the configured remark.js URL) or carry the nonce
+// of the response. Styles can be inline as remark.js adds its own.
+func contentSecurityPolicy(cfg *config.Config) string {
+	scripts := []string{"'self'", "'nonce-{nonce}'"}
+	if origin := remoteOrigin(cfg.FinalRemarkJS); origin != "" {
+		scripts = append(scripts, origin)
+	}
+	styles := []string{"'self'", "'unsafe-inline'"}
+	if origin := remoteOrigin(cfg.FinalStylesheet); origin != "" {
+		styles = append(styles, origin)
+	}
+	directives := []string{
+		"default-src 'self'",
+		"script-src " + strings.Join(scripts, " "),
+		"style-src " + strings.Join(styles, " "),
+		"img-src 'self' data: https:",
+		"font-src 'self' data: https:",
+		"media-src 'self' https:",
+		"frame-src https:",
+		"connect-src 'self'",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors 'self'",
+	}
+	return strings.Join(directives, "; ")
+}
+
+// remoteOrigin returns the origin of the given URL if it points to another
+// server and an empty string otherwise.
+func remoteOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if u.Scheme == "" {
+		return u.Host
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// nonces checks if pages have to carry a nonce.
+func (s *security) nonces() bool {
+	return s != nil && s.policy != ""
+}
+
+// setHeaders sets all security headers with the given nonce.
+func (s *security) setHeaders(h http.Header, nonce string) {
+	names := make([]string, 0, len(s.headers))
+	for name := range s.headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h.Set(name, s.headers[name])
+	}
+	if s.policy != "" {
+		h.Set(contentSecurityPolicyHeader, strings.Replace(s.policy, "{nonce}", nonce, -1))
+	}
+}
+
+// Handler sets the security headers for all responses of next. Pages
+// served through the render cache replace the nonce with their own.
+func (s *security) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.setHeaders(w.Header(), newNonce())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// nonceETag adds the nonce to the ETag of a cached page.
+func nonceETag(etag string, nonce string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + nonce + `"`
+}
+
+// matchNonceETag checks if the If-None-Match header refers to the given
+// ETag of a cached page and returns the nonce the client's copy of the page
+// was served with.
+func matchNonceETag(header string, etag string) (string, bool) {
+	prefix := strings.TrimSuffix(etag, `"`) + "-"
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if !strings.HasPrefix(candidate, prefix) || !strings.HasSuffix(candidate, `"`) {
+			continue
+		}
+		nonce := candidate[len(prefix) : len(candidate)-1]
+		if noncePattern.MatchString(nonce) {
+			return nonce, true
+		}
+	}
+	return "", false
+}
+
+// remoteIntegrity returns the Subresource Integrity hash of the file at the
+// given URL. A hash pinned in the configuration is verified against the
+// downloaded file. Without a pinned hash, the hash of the current version
+// is used. If the file cannot be downloaded, the pinned hash (if any) is
+// used as it is.
+func remoteIntegrity(cfg *config.Config, rawURL string, log *logrus.Logger) (string, error) {
+	pinned := cfg.Security.Integrity[rawURL]
+	if pinned != "" {
+		if err := integrity.Parse(pinned); err != nil {
+			return "", errors.Wrapf(err, "invalid integrity of %s", rawURL)
+		}
+	}
+	data, err := download(rawURL, log)
+	if err != nil {
+		log.WithError(err).Warnf("Failed to check integrity of %s", rawURL)
+		return pinned, nil
+	}
+	if pinned == "" {
+		hash := integrity.Compute(data)
+		log.Infof("Integrity of %s is %s (pin it with security.integrity)", rawURL, hash)
+		return hash, nil
+	}
+	if err := integrity.Verify(data, pinned); err != nil {
+		return "", errors.Wrapf(err, "%s doesn't match its pinned integrity", rawURL)
+	}
+	return pinned, nil
+}
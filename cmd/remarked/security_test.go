@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/integrity"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	cfg := &config.Config{FinalRemarkJS: "https://cdn.example.com/remark.js", FinalStylesheet: "/style/_.css"}
+	header := http.Header{}
+	newSecurity(cfg).setHeaders(header, "abc")
+	require.Equal(t, "nosniff", header.Get("X-Content-Type-Options"))
+	require.Equal(t, "SAMEORIGIN", header.Get("X-Frame-Options"))
+	policy := header.Get(contentSecurityPolicyHeader)
+	require.Contains(t, policy, "script-src 'self' 'nonce-abc' https://cdn.example.com;")
+	require.Contains(t, policy, "style-src 'self' 'unsafe-inline';")
+
+	cfg.Security.Headers = map[string]string{
+		"x-frame-options":         "",
+		"Permissions-Policy":      "camera=()",
+		"Content-Security-Policy": "script-src 'nonce-{nonce}'",
+	}
+	header = http.Header{}
+	sec := newSecurity(cfg)
+	sec.setHeaders(header, "abc")
+	require.Empty(t, header.Get("X-Frame-Options"))
+	require.Equal(t, "camera=()", header.Get("Permissions-Policy"))
+	require.Equal(t, "script-src 'nonce-abc'", header.Get(contentSecurityPolicyHeader))
+	require.True(t, sec.nonces())
+
+	cfg.Security.Headers = map[string]string{"Content-Security-Policy": ""}
+	header = http.Header{}
+	sec = newSecurity(cfg)
+	sec.setHeaders(header, "abc")
+	require.Empty(t, header.Get(contentSecurityPolicyHeader))
+	require.False(t, sec.nonces())
+
+	// Custom templates have to opt in.
+	cfg.TemplateFile = "template.html"
+	cfg.Security.Headers = nil
+	header = http.Header{}
+	sec = newSecurity(cfg)
+	sec.setHeaders(header, "abc")
+	require.Empty(t, header.Get(contentSecurityPolicyHeader))
+	require.Equal(t, "nosniff", header.Get("X-Content-Type-Options"))
+	require.False(t, sec.nonces())
+	cfg.Security.Headers = map[string]string{"Content-Security-Policy": "script-src 'nonce-{nonce}'"}
+	require.True(t, newSecurity(cfg).nonces())
+}
+
+func TestRenderCacheNonces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-security")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	markdown := filepath.Join(dir, "slides.md")
+	require.NoError(t, ioutil.WriteFile(markdown, []byte("# Hello"), 0644))
+	cfg := &config.Config{MarkdownFile: markdown, FinalRemarkJS: "/js/remark.js"}
+	cache := &renderCache{Security: newSecurity(cfg)}
+	serve := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		servePage(rec, req, "presentation", cfg, &context{RemarkJS: cfg.FinalRemarkJS}, cache, nil, logrus.New())
+		return rec
+	}
+
+	rec := serve("")
+	require.Equal(t, http.StatusOK, rec.Code)
+	nonce, ok := matchNonceETag(rec.Header().Get("ETag"), cache.entries["presentation"].etag)
+	require.True(t, ok)
+	require.Contains(t, rec.Header().Get(contentSecurityPolicyHeader), "'nonce-"+nonce+"'")
+	require.Equal(t, 2, strings.Count(rec.Body.String(), `nonce="`+nonce+`"`))
+	require.NotContains(t, rec.Body.String(), noncePlaceholder)
+	require.Empty(t, rec.Header().Get("Last-Modified"))
+
+	// Revalidating keeps the nonce of the client's copy.
+	etag := rec.Header().Get("ETag")
+	rec = serve(etag)
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	require.Equal(t, etag, rec.Header().Get("ETag"))
+	require.Contains(t, rec.Header().Get(contentSecurityPolicyHeader), "'nonce-"+nonce+"'")
+
+	// Every other response gets a new nonce.
+	for _, etag := range []string{"", `"other"`, strings.Replace(etag, nonce, "short", 1)} {
+		rec = serve(etag)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NotContains(t, rec.Header().Get(contentSecurityPolicyHeader), nonce)
+		require.NotContains(t, rec.Body.String(), nonce)
+	}
+}
+
+func TestRemoteIntegrity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/remark.js" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("var remark = {};"))
+	}))
+	defer server.Close()
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	src := server.URL + "/remark.js"
+	expected := integrity.Compute([]byte("var remark = {};"))
+
+	cfg := &config.Config{}
+	hash, err := remoteIntegrity(cfg, src, log)
+	require.NoError(t, err)
+	require.Equal(t, expected, hash)
+
+	cfg.Security.Integrity = map[string]string{src: expected}
+	hash, err = remoteIntegrity(cfg, src, log)
+	require.NoError(t, err)
+	require.Equal(t, expected, hash)
+	data, err := readRemarkJS(src, expected, log)
+	require.NoError(t, err)
+	require.Equal(t, "var remark = {};", string(data))
+
+	cfg.Security.Integrity[src] = integrity.Compute([]byte("changed"))
+	_, err = remoteIntegrity(cfg, src, log)
+	require.Error(t, err)
+	_, err = readRemarkJS(src, cfg.Security.Integrity[src], log)
+	require.Error(t, err)
+
+	// Pinned hashes are used as they are if the file is unavailable.
+	missing := server.URL + "/missing.js"
+	cfg.Security.Integrity[missing] = expected
+	hash, err = remoteIntegrity(cfg, missing, log)
+	require.NoError(t, err)
+	require.Equal(t, expected, hash)
+	hash, err = remoteIntegrity(cfg, server.URL+"/other.js", log)
+	require.NoError(t, err)
+	require.Empty(t, hash)
+
+	cfg.Security.Integrity[src] = "md5-abc"
+	_, err = remoteIntegrity(cfg, src, log)
+	require.Error(t, err)
+}
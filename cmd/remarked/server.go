@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"github.com/zerok/remarked/internal/commandchain"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/imaging"
+	"github.com/zerok/remarked/internal/remarkjs"
+	"github.com/zerok/remarked/internal/theme"
+	"github.com/zerok/remarked/internal/token"
+	"github.com/zerok/remarked/internal/watcher"
+)
+
+// deck serves a single presentation. The routes depending on the
+// configuration can be replaced at any time using Update while the hub (and
+// therefore all connected websocket clients) stays the same.
+type deck struct {
+	ConfigPath string
+	Flags      *overrides
+
+	// BasePath is the URL prefix the deck is served under. It is empty if
+	// the deck is served at the root.
+	BasePath string
+
+	// If BaseDir is set, all relative paths inside the configuration are
+	// resolved against it instead of the current working directory.
+	BaseDir string
+
+	// Token is the guide token that is kept across configuration reloads.
+	Token string
+
+	Hub    *commandchain.Hub
+	Reload *liveReload
+	Guide  bool
+	Log    *logrus.Logger
+
+	lock    sync.RWMutex
+	cfg     *config.Config
+	handler http.Handler
+	warm    func() error
+}
+
+// Load (re-)reads the configuration file of the deck and updates the routes
+// accordingly.
+func (d *deck) Load() error {
+	cfg, _, err := loadConfig(d.ConfigPath, d.Flags, d.BaseDir, d.Log)
+	if err != nil {
+		return err
+	}
+	cfg.Token = d.Token
+	return d.Update(cfg)
+}
+
+// Config returns the configuration that is currently in use.
+func (d *deck) Config() *config.Config {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.cfg
+}
+
+// watch reloads the configuration of the deck whenever it changes.
+func (d *deck) watch() {
+	watchConfig(d.ConfigPath, d.Log, func() {
+		if err := d.Load(); err != nil {
+			d.Log.WithError(err).Errorf("Failed to reload config file: %s", d.ConfigPath)
+			return
+		}
+		d.Log.Infof("Reloaded configuration from %s", d.ConfigPath)
+		d.Reload.Notify()
+	})
+}
+
+// Update builds all routes for the given configuration and replaces the
+// previous ones in one go. The presentation is rendered before it is served,
+// so that its code snippets don't run inside the first request where they
+// could easily exceed the write timeout of the server.
+func (d *deck) Update(cfg *config.Config) error {
+	mux, warm, err := d.buildMux(cfg)
+	if err != nil {
+		return err
+	}
+	if err := warm(); err != nil {
+		d.Log.WithError(err).Warn("Failed to render presentation")
+	}
+	d.lock.Lock()
+	d.cfg = cfg
+	d.handler = mux
+	d.warm = warm
+	d.lock.Unlock()
+	d.Reload.Watch(presentationInputs(cfg)...)
+	return nil
+}
+
+// Warm renders the presentation into the render cache unless the cached
+// version is still up to date.
+func (d *deck) Warm() {
+	d.lock.RLock()
+	warm := d.warm
+	d.lock.RUnlock()
+	if warm == nil {
+		return
+	}
+	if err := warm(); err != nil {
+		d.Log.WithError(err).Warn("Failed to render presentation")
+	}
+}
+
+func (d *deck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.lock.RLock()
+	handler := d.handler
+	d.lock.RUnlock()
+	handler.ServeHTTP(w, r)
+}
+
+// buildMux returns the routes for the given configuration together with a
+// function that renders the presentation into the render cache.
+func (d *deck) buildMux(cfg *config.Config) (http.Handler, func() error, error) {
+	log := d.Log
+	mux := http.NewServeMux()
+	play := &playground{}
+	cfg.BasePath = d.BasePath
+
+	if d.Reload != nil {
+		mux.HandleFunc("/ws/reload", reloadWebsocketHandler(d.Hub, log))
+	}
+
+	if cfg.RemarkJS == "" {
+		mux.HandleFunc(remarkJSMountPoint, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/javascript")
+			http.ServeContent(w, r, "remark.js", time.Time{}, strings.NewReader(remarkjs.Source()))
+		})
+		cfg.FinalRemarkJS = d.BasePath + remarkJSMountPoint
+	} else if localRemarkJS, ok := isLocalFile(cfg.RemarkJS); ok {
+		mux.HandleFunc(remarkJSMountPoint, func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, localRemarkJS)
+		})
+		cfg.FinalRemarkJS = d.BasePath + remarkJSMountPoint
+	} else {
+		cfg.FinalRemarkJS = cfg.RemarkJS
+		hash, err := remoteIntegrity(cfg, cfg.RemarkJS, log)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.FinalRemarkJSIntegrity = hash
+	}
+
+	localStylesheet, ok := isLocalFile(cfg.Stylesheet)
+	if ok {
+		mux.HandleFunc(stylesheetMountPoint, func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, localStylesheet)
+		})
+		cfg.FinalStylesheet = d.BasePath + stylesheetMountPoint
+	} else if cfg.Stylesheet != "" {
+		cfg.FinalStylesheet = cfg.Stylesheet
+		hash, err := remoteIntegrity(cfg, cfg.Stylesheet, log)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.FinalStylesheetIntegrity = hash
+	}
+
+	sec := newSecurity(cfg)
+	cache := &renderCache{Log: log, Security: sec, Since: time.Now(), Disabled: cfg.NoCache}
+
+	th, err := loadTheme(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if th != nil {
+		mux.Handle(themeMountPoint, http.StripPrefix(themeMountPoint, themeHandler(th)))
+		cfg.FinalThemeStylesheet = d.BasePath + themeMountPoint + theme.StylesheetFile
+	}
+
+	if cfg.Play.Enabled {
+		if cfg.Play.Audience {
+			mux.HandleFunc("/ws/run", runWebsocketHandler(play, log))
+		} else if d.Guide {
+			mux.HandleFunc("/ws/run", token.Require(cfg.Token, d.BasePath+"/guide/login", runWebsocketHandler(play, log)))
+		}
+	}
+
+	var images *imaging.Cache
+	if cfg.StaticFolder != "" {
+		fullStaticFolder, err := filepath.Abs(cfg.StaticFolder)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to resolve absolute path to static folder %s", cfg.StaticFolder)
+		}
+		images, err = newImageCache(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Debugf("Serving static files from %s", fullStaticFolder)
+		mux.Handle("/static/", http.StripPrefix("/static/", staticHandler(fullStaticFolder, cfg, images, log)))
+	}
+
+	if d.Guide {
+		mux.HandleFunc("/guide/login", guideLoginHandler(cfg, log))
+		mux.HandleFunc("/guide", token.Require(cfg.Token, d.BasePath+"/guide/login", guideHandler(cfg, cache, play, images, d.Reload, log)))
+		mux.HandleFunc("/ws/guide", guideWebsocketHandler(cfg, d.Hub, log))
+		mux.HandleFunc("/ws/guided", guidedWebsocketHandler(cfg, d.Hub, log))
+	}
+
+	mux.HandleFunc("/", presentationHandler(cfg, d.Guide, cache, play, images, d.Reload, log))
+	warm := func() error {
+		ctx := presentationContext(cfg, d.Guide, play, images, d.Reload)
+		return cache.Warm("presentation", pageRenderer(cfg, ctx, cache, d.Reload))
+	}
+	return sec.Handler(mux), warm, nil
+}
+
+func presentationHandler(cfg *config.Config, guide bool, cache *renderCache, play *playground, images *imaging.Cache, reload *liveReload, log *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		servePage(w, r, "presentation", cfg, presentationContext(cfg, guide, play, images, reload), cache, reload, log)
+	}
+}
+
+func presentationContext(cfg *config.Config, guide bool, play *playground, images *imaging.Cache, reload *liveReload) *context {
+	return &context{
+		RemarkJS:            cfg.FinalRemarkJS,
+		StyleSheetURL:       cfg.FinalStylesheet,
+		ThemeStylesheetURL:  cfg.FinalThemeStylesheet,
+		RemarkJSIntegrity:   cfg.FinalRemarkJSIntegrity,
+		StylesheetIntegrity: cfg.FinalStylesheetIntegrity,
+		Title:               cfg.Title,
+		IsGuided:            guide,
+		LiveReload:          reload != nil,
+		BasePath:            cfg.BasePath,
+		Runnable:            cfg.Play.Enabled && cfg.Play.Audience,
+		play:                play,
+		images:              images,
+	}
+}
+
+// pageError is returned if a page could not be rendered. Its message is
+// shown to the client while the wrapped error is only logged.
+type pageError struct {
+	message string
+	err     error
+}
+
+func (e *pageError) Error() string {
+	return e.err.Error()
+}
+
+// servePage responds with the presentation rendered with the given context.
+// The output is cached until one of the files it depends on changes.
+func servePage(w http.ResponseWriter, r *http.Request, key string, cfg *config.Config, ctx *context, cache *renderCache, reload *liveReload, log *logrus.Logger) {
+	err := cache.Serve(w, r, key, pageRenderer(cfg, ctx, cache, reload))
+	if err != nil {
+		message := "Failed to render presentation"
+		if perr, ok := err.(*pageError); ok {
+			message = perr.message
+		}
+		log.WithError(err).Error(message)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
+
+// pageRenderer returns the function that renders the presentation with the
+// given context for the render cache.
+func pageRenderer(cfg *config.Config, ctx *context, cache *renderCache, reload *liveReload) renderFunc {
+	if cache.Security.nonces() {
+		ctx.Nonce = noncePlaceholder
+	}
+	return func() ([]byte, []string, error) {
+		body, inputs, err := renderPage(cfg, ctx)
+		reload.Watch(inputs...)
+		return body, inputs, err
+	}
+}
+
+// renderPage renders the presentation into the output template and returns
+// the result together with all files that were read for it.
+func renderPage(cfg *config.Config, ctx *context) ([]byte, []string, error) {
+	inputs := []string{cfg.MarkdownFile}
+	if cfg.TemplateFile != "" {
+		inputs = append(inputs, cfg.TemplateFile)
+	}
+	th, err := loadTheme(cfg)
+	if err != nil {
+		return nil, inputs, &pageError{message: "Failed to load theme", err: err}
+	}
+	if th != nil {
+		inputs = append(inputs, th.Files()...)
+	}
+	ctx.RemarkOptions = remarkOptions(cfg, th)
+	tmpl, err := loadOutputTemplate(cfg.TemplateFile, th)
+	if err != nil {
+		return nil, inputs, &pageError{message: "Failed to parse template file", err: err}
+	}
+	data, err := ioutil.ReadFile(cfg.MarkdownFile)
+	if err != nil {
+		return nil, inputs, &pageError{message: "Failed to read file", err: err}
+	}
+	funcs := newTemplateFuncs(cfg)
+	funcs.Play = ctx.play
+	funcs.Images = ctx.images
+	content, err := buildContent(string(data), cfg, funcs)
+	inputs = append(inputs, funcs.Files()...)
+	if err != nil {
+		return nil, inputs, &pageError{message: "Failed to compile output", err: err}
+	}
+	ctx.Source = rewriteBasePath(content, cfg.BasePath)
+	var output bytes.Buffer
+	if err := tmpl.Execute(&output, ctx); err != nil {
+		return nil, inputs, &pageError{message: "Failed to render output", err: err}
+	}
+	return output.Bytes(), inputs, nil
+}
+
+// rewriteBasePath prefixes all references to the /static mountpoint inside
+// the content with the base path of the deck.
+func rewriteBasePath(content string, basePath string) string {
+	if basePath == "" {
+		return content
+	}
+	return rewriteStaticURLs(content, basePath+"/")
+}
+
+// watchConfig calls onChange whenever the configuration file at the given
+// path changes or the process receives a SIGHUP.
+func watchConfig(path string, log *logrus.Logger, onChange func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	w := watcher.Watcher{Log: log}
+	w.Add(path)
+	go w.Run(nil, func(changed []string) {
+		onChange()
+	})
+	for range signals {
+		log.Info("Received SIGHUP")
+		onChange()
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/base64"
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"github.com/zerok/remarked/internal/config"
+)
+
+var inlineReferencePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(!\[[^\]]*\]\()([^)\s]+)`),
+	regexp.MustCompile(`(<img\s[^>]*src=["'])([^"']+)`),
+	regexp.MustCompile(`(url\(\s*["']?)([^"')\s]+)`),
+}
+
+var closingScriptPattern = regexp.MustCompile(`(?i)</(script|style)`)
+
+// exportSingleFile renders the presentation into a single HTML file that
+// has remark.js, the stylesheet and all referenced images inlined. Like
+// exportPresentation, it returns the files read on top of the
+// presentationInputs.
+func exportSingleFile(cfg *config.Config, target string, log *logrus.Logger) ([]string, error) {
+	ctx := context{
+		Title: cfg.Title,
+	}
+	inl := inliner{staticFolder: cfg.StaticFolder, log: log}
+
+	remarkJS, err := readRemarkJS(cfg.RemarkJS, cfg.Security.Integrity[cfg.RemarkJS], log)
+	if err != nil {
+		return nil, err
+	}
+	ctx.InlineRemarkJS = template.JS(escapeClosingTags(string(remarkJS)))
+
+	th, err := loadTheme(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if th != nil {
+		ctx.InlineThemeStylesheet = template.CSS(escapeClosingTags(inl.inline(th.Stylesheet, th.Dir)))
+	}
+	ctx.RemarkOptions = remarkOptions(cfg, th)
+
+	if localStylesheet, ok := isLocalFile(cfg.Stylesheet); ok {
+		data, err := ioutil.ReadFile(localStylesheet)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read stylesheet %s", localStylesheet)
+		}
+		css := inl.inline(string(data), filepath.Dir(localStylesheet))
+		ctx.InlineStylesheet = template.CSS(escapeClosingTags(css))
+	} else if cfg.Stylesheet != "" {
+		log.Warnf("Could not inline stylesheet %s", cfg.Stylesheet)
+		hash, err := remoteIntegrity(cfg, cfg.Stylesheet, log)
+		if err != nil {
+			return nil, err
+		}
+		ctx.StyleSheetURL = cfg.Stylesheet
+		ctx.StylesheetIntegrity = hash
+	}
+
+	content, funcs, err := exportContent(cfg)
+	inputs := exportInputs(th, funcs)
+	if err != nil {
+		return inputs, err
+	}
+	inl.images, err = resizeImageVariants(cfg, funcs.ImageVariants())
+	if err != nil {
+		return inputs, err
+	}
+	// Only the image in src is inlined as every entry of a srcset would
+	// have to be included.
+	content = srcsetPattern.ReplaceAllString(content, "")
+	ctx.Source = inl.inline(content, filepath.Dir(cfg.MarkdownFile))
+
+	output, err := exportOutput(cfg, th, &ctx)
+	if err != nil {
+		return inputs, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return inputs, errors.Wrapf(err, "failed to create %s", filepath.Dir(target))
+	}
+	if err := ioutil.WriteFile(target, output, 0644); err != nil {
+		return inputs, errors.Wrapf(err, "failed to write %s", target)
+	}
+	return inputs, nil
+}
+
+// escapeClosingTags prevents inlined scripts and stylesheets from closing
+// the element they are embedded in.
+func escapeClosingTags(s string) string {
+	return closingScriptPattern.ReplaceAllString(s, `<\/$1`)
+}
+
+// inliner replaces references to images and other files with data URIs.
+type inliner struct {
+	staticFolder string
+	log          *logrus.Logger
+
+	// images maps the URLs of resized images to the resized files.
+	images map[string]string
+}
+
+// inline replaces all Markdown images, img elements and CSS url() references
+// inside the given content. Relative paths are resolved against baseDir while
+// paths starting with /static/ are resolved against the static folder.
+func (i *inliner) inline(content string, baseDir string) string {
+	for _, pattern := range inlineReferencePatterns {
+		content = pattern.ReplaceAllStringFunc(content, func(match string) string {
+			groups := pattern.FindStringSubmatch(match)
+			uri, ok := i.dataURI(groups[2], baseDir)
+			if !ok {
+				return match
+			}
+			return groups[1] + uri
+		})
+	}
+	return content
+}
+
+func (i *inliner) dataURI(ref string, baseDir string) (string, bool) {
+	if strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+		return "", false
+	}
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "//") {
+		i.log.Warnf("Could not inline remote reference %s", ref)
+		return "", false
+	}
+	path := ref
+	if idx := strings.IndexAny(path, "?#"); idx != -1 {
+		path = path[:idx]
+	}
+	if resized, found := i.images[ref]; found {
+		path = resized
+	} else if strings.HasPrefix(path, "/static/") && i.staticFolder != "" {
+		path = filepath.Join(i.staticFolder, filepath.FromSlash(strings.TrimPrefix(path, "/static/")))
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, filepath.FromSlash(path))
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		i.log.Warnf("Could not inline %s: %s", ref, err.Error())
+		return "", false
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), true
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-inline")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "static"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "static", "a.png"), []byte("a"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.svg"), []byte("b"), 0644))
+
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	inl := inliner{staticFolder: filepath.Join(dir, "static"), log: log}
+
+	input := "![a](/static/a.png)\n<img src=\"b.svg\">\nurl('missing.png')\n![remote](https://example.com/c.png)"
+	expected := "![a](data:image/png;base64,YQ==)\n<img src=\"data:image/svg+xml;base64,Yg==\">\nurl('missing.png')\n![remote](https://example.com/c.png)"
+	require.Equal(t, expected, inl.inline(input, dir))
+}
+
+func TestEscapeClosingTags(t *testing.T) {
+	require.Equal(t, `var a = "<\/script>";`, escapeClosingTags(`var a = "</script>";`))
+}
@@ -5,28 +5,120 @@ import (
 	"io/ioutil"
 
 	"github.com/pkg/errors"
+	"github.com/zerok/remarked/internal/theme"
 )
 
+// outputTemplate is the base of all output templates. Custom templates can
+// replace it completely or only (re-)define some of its blocks:
+//
+//	head            title and meta elements
+//	styles          the stylesheets of the theme and the presentation
+//	remark-options  the options passed to remark.create
+//	scripts         additional scripts, run after the slideshow was created
+//	                (they need nonce="{{ .Nonce }}" to pass the
+//	                Content-Security-Policy)
+//	body-end        anything at the end of the body
 var outputTemplate = `<!DOCTYPE html>
 <html>
   <head>
+	{{ block "head" . }}
 	<title>{{ .Title }}</title>
 	<meta name="viewport" content="width=device-width, initial-scale=1">
 	<meta charset="utf-8">
-	{{ if .StyleSheetURL }}
-	<link rel="stylesheet" href="{{ .StyleSheetURL }}">
+	{{ end }}
+	{{ block "styles" . }}
+	{{ if .InlineThemeStylesheet }}
+	<style>{{ .InlineThemeStylesheet }}</style>
+	{{ else if .ThemeStylesheetURL }}
+	<link rel="stylesheet" href="{{ .ThemeStylesheetURL }}">
+	{{ end }}
+	{{ if .InlineStylesheet }}
+	<style>{{ .InlineStylesheet }}</style>
+	{{ else if .StyleSheetURL }}
+	<link rel="stylesheet" href="{{ .StyleSheetURL }}"{{ if .StylesheetIntegrity }} integrity="{{ .StylesheetIntegrity }}" crossorigin="anonymous"{{ end }}>
+	{{ end }}
 	{{ end }}
   </head>
   <body>
 	<textarea id="source">{{.Source}}</textarea>
-    <script src="{{ .RemarkJS }}"></script>
-    <script>
-      var slideshow = remark.create({
-		highlightLines: true
-	  });
+    {{ if .InlineRemarkJS }}
+    <script{{ if .Nonce }} nonce="{{ .Nonce }}"{{ end }}>{{ .InlineRemarkJS }}</script>
+    {{ else }}
+    <script src="{{ .RemarkJS }}"{{ if .RemarkJSIntegrity }} integrity="{{ .RemarkJSIntegrity }}" crossorigin="anonymous"{{ end }}{{ if .Nonce }} nonce="{{ .Nonce }}"{{ end }}></script>
+    {{ end }}
+    <script{{ if .Nonce }} nonce="{{ .Nonce }}"{{ end }}>
+      var slideshow = remark.create({{ block "remark-options" . }}{{ .RemarkOptions }}{{ end }});
+	  {{ if .LiveReload }}
+	  (function() {
+	    var storedIndex = window.sessionStorage.getItem('remarked.slideIndex');
+	    if (storedIndex !== null) {
+	      window.sessionStorage.removeItem('remarked.slideIndex');
+	      slideshow.gotoSlide(parseInt(storedIndex, 10) + 1);
+	    }
+	    function connectReload() {
+	      var socket = new WebSocket((window.location.protocol === "https:" ? "wss://" : "ws://") + window.location.host + "{{ .BasePath }}/ws/reload");
+	      socket.onmessage = function(evt) {
+	        var cmd = JSON.parse(evt.data);
+	        if (cmd.type === 'reload') {
+	          window.sessionStorage.setItem('remarked.slideIndex', slideshow.getCurrentSlideIndex());
+	          window.location.reload();
+	        }
+	      };
+	      socket.onclose = function() {
+	        window.setTimeout(function() {connectReload();}, 2000);
+	      };
+	    }
+	    connectReload();
+	  })();
+	  {{ end }}
+	  {{ if .Runnable }}
+	  (function() {
+	    var blocks = document.querySelectorAll('.runnable');
+	    Array.prototype.forEach.call(blocks, function(block) {
+	      var id = null;
+	      Array.prototype.forEach.call(block.classList, function(name) {
+	        if (name.indexOf('run-') === 0) {
+	          id = name.substring(4);
+	        }
+	      });
+	      if (id === null) {
+	        return;
+	      }
+	      var button = document.createElement('button');
+	      button.className = 'remarked-run';
+	      button.textContent = 'Run';
+	      var output = document.createElement('pre');
+	      output.className = 'remarked-run-output';
+	      output.style.display = 'none';
+	      block.appendChild(button);
+	      block.appendChild(output);
+	      button.addEventListener('click', function(evt) {
+	        evt.stopPropagation();
+	        button.disabled = true;
+	        output.textContent = '';
+	        output.style.display = '';
+	        var socket = new WebSocket((window.location.protocol === "https:" ? "wss://" : "ws://") + window.location.host + "{{ .BasePath }}/ws/run");
+	        socket.onopen = function() {
+	          socket.send(JSON.stringify({id: id}));
+	        };
+	        socket.onmessage = function(evt) {
+	          var msg = JSON.parse(evt.data);
+	          if (msg.type === 'output') {
+	            output.textContent += msg.data;
+	          } else if (msg.type === 'exit' && msg.data) {
+	            output.textContent += '\n(' + msg.data + ')';
+	          }
+	        };
+	        socket.onclose = function() {
+	          button.disabled = false;
+	        };
+	      });
+	    });
+	  })();
+	  {{ end }}
 	  {{ if or .IsGuided }}
 	  function connect() {
-	  var socket = new WebSocket((window.location.protocol === "https:" ? "wss://" : "ws://") + window.location.host + "/ws/guide{{ if not .IsGuide }}d{{ end }}");
+	  var socket = new WebSocket((window.location.protocol === "https:" ? "wss://" : "ws://") + window.location.host + "{{ .BasePath }}/ws/guide{{ if not .IsGuide }}d{{ end }}");
 	  window.addEventListener('close', function() {
 	    socket.close();
 	  });
@@ -64,24 +156,36 @@ var outputTemplate = `<!DOCTYPE html>
 	  connect();
 	  {{ end }}
     </script>
+    {{ block "scripts" . }}{{ end }}
+    {{ block "body-end" . }}{{ end }}
   </body>
 </html>
 `
 
-func loadOutputTemplate(path string) (*template.Template, error) {
-	var rawTemplate string
-	if path == "" {
-		rawTemplate = outputTemplate
-	} else {
+var baseOutputTemplate = template.Must(template.New("ROOT").Parse(outputTemplate))
+
+// loadOutputTemplate returns the output template. The template of the
+// theme and the template file at the given path (if set) are parsed on top
+// of the default template in that order, so they only have to define the
+// blocks they change.
+func loadOutputTemplate(path string, th *theme.Theme) (*template.Template, error) {
+	tmpl, err := baseOutputTemplate.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if th != nil && th.Template != "" {
+		if _, err := tmpl.Parse(th.Template); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse template of theme %s", th.Name)
+		}
+	}
+	if path != "" {
 		data, err := ioutil.ReadFile(path)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to read template file %s", path)
 		}
-		rawTemplate = string(data)
-	}
-	tmpl, err := template.New("ROOT").Parse(rawTemplate)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to parse template file %s", path)
+		if _, err := tmpl.Parse(string(data)); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse template file %s", path)
+		}
 	}
 	return tmpl, nil
 }
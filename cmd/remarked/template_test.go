@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/theme"
+)
+
+func TestLoadOutputTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-template")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	render := func(path string, th *theme.Theme) string {
+		tmpl, err := loadOutputTemplate(path, th)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		require.NoError(t, tmpl.Execute(&out, &context{Title: "Talk", StyleSheetURL: "/style/_.css", RemarkOptions: "{}"}))
+		return out.String()
+	}
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	page := render("", nil)
+	require.Contains(t, page, "<title>Talk</title>")
+	require.Contains(t, page, `href="/style/_.css"`)
+	require.Contains(t, page, "remark.create({});")
+
+	// Templates that only define blocks keep the rest of the default page.
+	page = render(write("blocks.html", `{{ define "styles" }}<link rel="stylesheet" href="/custom.css">{{ end }}
+{{ define "remark-options" }}{"ratio":"16:9"}{{ end }}
+{{ define "body-end" }}<footer>{{ .Title }}</footer>{{ end }}`), nil)
+	require.Contains(t, page, "<title>Talk</title>")
+	require.Contains(t, page, `href="/custom.css"`)
+	require.NotContains(t, page, `href="/style/_.css"`)
+	require.Contains(t, page, `remark.create({"ratio":"16:9"});`)
+	require.True(t, strings.Index(page, "remark.create") < strings.Index(page, "<footer>Talk</footer>"))
+	require.True(t, strings.HasSuffix(strings.TrimSpace(page), "<footer>Talk</footer>\n  </body>\n</html>"))
+
+	// Everything outside of define replaces the whole page.
+	page = render(write("full.html", "<pre>{{ .Title }}</pre>"), nil)
+	require.Equal(t, "<pre>Talk</pre>", page)
+
+	// The template file is applied on top of the theme's template.
+	th := &theme.Theme{Name: "plain", Template: `{{ define "head" }}<title>Theme</title>{{ end }}{{ define "scripts" }}<script src="/theme.js"></script>{{ end }}`}
+	page = render(write("scripts.html", `{{ define "scripts" }}<script src="/deck.js"></script>{{ end }}`), th)
+	require.Contains(t, page, "<title>Theme</title>")
+	require.Contains(t, page, `<script src="/deck.js"></script>`)
+	require.NotContains(t, page, "/theme.js")
+
+	_, err = loadOutputTemplate(write("broken.html", `{{ define "head" }}`), nil)
+	require.Error(t, err)
+	_, err = loadOutputTemplate(filepath.Join(dir, "missing.html"), nil)
+	require.Error(t, err)
+}
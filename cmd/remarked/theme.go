@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/theme"
+)
+
+const themeMountPoint = "/theme/"
+
+// loadTheme loads the theme set in the configuration. If no theme is set,
+// nil is returned.
+func loadTheme(cfg *config.Config) (*theme.Theme, error) {
+	if cfg.Theme == "" {
+		return nil, nil
+	}
+	th, err := theme.Load(cfg.Theme, theme.UserDir())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load theme")
+	}
+	return th, nil
+}
+
+// remarkOptions returns the options passed to remark.create as JSON. The
+// options of the theme override the defaults of remarked and are in turn
+// overridden by the remark section of the configuration. As the JSON
+// encoder escapes <, > and &, the result can be embedded into a script
+// element as it is.
+func remarkOptions(cfg *config.Config, th *theme.Theme) template.JS {
+	options := map[string]interface{}{"highlightLines": true}
+	if th != nil {
+		config.MergeRemark(options, th.Remark)
+	}
+	config.MergeRemark(options, cfg.Remark)
+	data, err := json.Marshal(options)
+	if err != nil {
+		return "{}"
+	}
+	return template.JS(data)
+}
+
+// themeHandler serves the stylesheet of the theme and, for theme folders,
+// all the other files inside it.
+func themeHandler(th *theme.Theme) http.Handler {
+	if th.Dir != "" {
+		return http.FileServer(http.Dir(th.Dir))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != theme.StylesheetFile {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		http.ServeContent(w, r, theme.StylesheetFile, time.Time{}, strings.NewReader(th.Stylesheet))
+	})
+}
+
+// exportTheme writes the stylesheet of the theme into the target folder. For
+// theme folders, all other files are copied as well.
+func exportTheme(th *theme.Theme, target string, exclude string) error {
+	if th.Dir != "" {
+		return copyFolder(th.Dir, target, exclude)
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create %s", target)
+	}
+	path := filepath.Join(target, theme.StylesheetFile)
+	if err := ioutil.WriteFile(path, []byte(th.Stylesheet), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+	return nil
+}
+
+// runTheme implements the theme command.
+func runTheme(args []string, output io.Writer) error {
+	if len(args) != 1 || args[0] != "list" {
+		return fmt.Errorf("usage: remarked theme list")
+	}
+	w := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSOURCE\tDESCRIPTION")
+	for _, th := range theme.List(theme.UserDir()) {
+		source := th.Dir
+		if source == "" {
+			source = "built-in"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", th.Name, source, th.Description)
+	}
+	return w.Flush()
+}
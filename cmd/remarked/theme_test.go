@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/theme"
+)
+
+func TestRemarkOptions(t *testing.T) {
+	require.Equal(t, template.JS(`{"highlightLines":true}`), remarkOptions(&config.Config{}, nil))
+	th := &theme.Theme{Remark: map[string]interface{}{"highlightLines": false, "ratio": "16:9"}}
+	require.Equal(t, template.JS(`{"highlightLines":false,"ratio":"16:9"}`), remarkOptions(&config.Config{}, th))
+
+	// The configuration overrides the theme, including --remark flags.
+	flags := &overrides{}
+	require.NoError(t, flags.parseRemark([]string{"navigation.scroll=false", "highlightLines=true"}))
+	file := config.Layer{Source: "remarked.yml", Values: map[string]interface{}{
+		"remark": map[string]interface{}{"ratio": "4:3", "slideNumberFormat": "</script>"},
+	}}
+	cfg, _, err := config.Load(append([]config.Layer{config.Defaults(), file}, flags.layers()...)...)
+	require.NoError(t, err)
+	require.Equal(t, template.JS(`{"highlightLines":true,"navigation":{"scroll":false},"ratio":"4:3","slideNumberFormat":"\u003c/script\u003e"}`), remarkOptions(cfg, th))
+	require.Error(t, flags.parseRemark([]string{"ratio=wide"}))
+}
+
+func TestRenderPageWithTheme(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-theme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	markdown := filepath.Join(dir, "slides.md")
+	require.NoError(t, ioutil.WriteFile(markdown, []byte("# Hello"), 0644))
+	cfg := &config.Config{MarkdownFile: markdown, Theme: "dark"}
+
+	ctx := &context{ThemeStylesheetURL: "/theme/style.css", StyleSheetURL: "/style/_.css"}
+	output, _, err := renderPage(cfg, ctx)
+	require.NoError(t, err)
+	page := string(output)
+	require.True(t, strings.Index(page, `href="/theme/style.css"`) < strings.Index(page, `href="/style/_.css"`))
+	require.Contains(t, page, `remark.create({"highlightLines":true,"highlightStyle":"monokai"})`)
+
+	// A theme folder can replace the output template.
+	folder := filepath.Join(dir, "themes", "plain")
+	require.NoError(t, os.MkdirAll(folder, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(folder, theme.StylesheetFile), nil, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(folder, theme.TemplateFile), []byte("<pre>{{ .Source }}</pre>"), 0644))
+	cfg.Theme = folder
+	output, inputs, err := renderPage(cfg, &context{})
+	require.NoError(t, err)
+	require.Equal(t, "<pre># Hello</pre>", string(output))
+	require.Contains(t, inputs, filepath.Join(folder, theme.TemplateFile))
+
+	cfg.Theme = "missing"
+	_, _, err = renderPage(cfg, &context{})
+	require.Error(t, err)
+}
+
+func TestRunTheme(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, runTheme([]string{"list"}, &output))
+	require.True(t, strings.HasPrefix(output.String(), "NAME"))
+	require.Contains(t, output.String(), "dark ")
+	require.Contains(t, output.String(), "built-in")
+	require.Error(t, runTheme(nil, &output))
+}
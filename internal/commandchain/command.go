@@ -1,5 +1,9 @@
 package commandchain
 
+// ReloadCommand asks all receivers to reload the presentation.
+const ReloadCommand = "reload"
+
+// Command is sent from a commander (or remarked itself) to all receivers.
 type Command struct {
 	Type       string `json:"type"`
 	SlideIndex int    `json:"slideIndex"`
@@ -54,7 +54,6 @@ loop:
 		}
 		c.Hub.BroadcastCommand(cmd, c)
 	}
-	return nil
 }
 func (c *Commander) String() string {
 	if c.Conn == nil {
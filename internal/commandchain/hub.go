@@ -6,6 +6,10 @@ import (
 	"github.com/Sirupsen/logrus"
 )
 
+// receiverBuffer is the number of commands that are queued for a receiver
+// before further commands to it are dropped.
+const receiverBuffer = 16
+
 // Hub acts as the main control unit that is used to broadcast commands issued
 // by a commander to the receivers.
 type Hub struct {
@@ -25,19 +29,28 @@ func (h *Hub) RegisterReceiver(r *Receiver) error {
 	if h.Log != nil {
 		h.Log.Infof("Registering receiver %s", r)
 	}
-	r.Commands = make(chan Command)
+	r.Commands = make(chan Command, receiverBuffer)
 	h.receivers[r] = struct{}{}
 	return nil
 }
 
 // BroadcastCommand is used by a commander to issue a specific command to all
-// registered receivers.
+// registered receivers. Commands that are not issued by a commander but by
+// remarked itself (e.g. "reload") are sent with a nil commander. Receivers
+// that don't keep up (e.g. because their connection is already gone) don't
+// block the broadcast, the command is dropped for them instead.
 func (h *Hub) BroadcastCommand(cmd Command, c *Commander) {
 	h.lock.RLock()
 	defer h.lock.RUnlock()
 	for r := range h.receivers {
-		if r != nil {
-			r.Commands <- cmd
+		if r != nil && (r.Accept == nil || r.Accept(cmd)) {
+			select {
+			case r.Commands <- cmd:
+			default:
+				if h.Log != nil {
+					h.Log.Warnf("Dropping %s command for %s", cmd.Type, r)
+				}
+			}
 		}
 	}
 }
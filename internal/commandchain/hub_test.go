@@ -0,0 +1,31 @@
+package commandchain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/commandchain"
+)
+
+func TestBroadcastDoesNotBlock(t *testing.T) {
+	hub := &commandchain.Hub{}
+	recv := &commandchain.Receiver{}
+	require.NoError(t, hub.RegisterReceiver(recv))
+
+	// Nobody reads the commands of the receiver, so all broadcasts beyond
+	// its buffer have to be dropped.
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			hub.BroadcastCommand(commandchain.Command{Type: commandchain.ReloadCommand}, nil)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("broadcast blocked")
+	}
+	require.NoError(t, hub.UnregisterReceiver(recv))
+}
@@ -16,6 +16,10 @@ type Receiver struct {
 	Conn     *websocket.Conn
 	Commands chan Command
 	Log      *logrus.Logger
+
+	// Accept can be used to limit the commands forwarded to this receiver.
+	// If it is not set, all commands are forwarded.
+	Accept func(Command) bool
 }
 
 // Handle waits for input from the commands channel in order to forward the
@@ -52,7 +56,6 @@ func (r *Receiver) Handle(ctx context.Context) error {
 			}
 		}
 	}
-	return nil
 }
 
 func (r *Receiver) String() string {
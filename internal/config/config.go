@@ -2,9 +2,8 @@ package config
 
 import (
 	"fmt"
-	"io/ioutil"
-
-	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"time"
 )
 
 // Sample is the content of the remark.yml file as generated with the
@@ -13,13 +12,28 @@ const Sample = `
 # Set the title that should be rendered in the browser's title bar.
 title: Slides
 
+# Use one of the built-in themes (see "remarked theme list") or the theme
+# inside the given folder. The stylesheet is applied on top of it.
+# theme: dark
+
+# Options passed to remark.create. They override those of the theme:
+# remark:
+#   ratio: "16:9"
+#   highlightStyle: github
+#   highlightLanguage: go
+#   countIncrementalSlides: false
+#   slideNumberFormat: "%current% / %total%"
+#   navigation:
+#     scroll: false
+
 # Set the path to a local CSS file for remarked to serve.
 # stylesheet: style.css
 
 # Markdown file
 markdownFile: slides.md
 
-# RemarkJS allows overriding the default RemarkJS file.
+# RemarkJS allows overriding the RemarkJS file embedded into remarked. This
+# can either be a URL or the path to a local file.
 # remarkJS: "https://remarkjs.com/downloads/remark-latest.min.js"
 
 # The folder that should be served under /static. Default: none
@@ -31,6 +45,68 @@ markdownFile: slides.md
 
 # leftActionDelimiter: "{{"
 # rightActionDelimiter: "}}"
+
+# Data that is available as .Data inside the Markdown file if it is used as
+# template:
+# data:
+#   event: GopherCon
+#   speaker: Jane Doe
+
+# Environment variables that are available as .Env inside the Markdown file
+# if it is used as template:
+# env:
+#   - USER
+
+# Rendered presentations are cached until one of their files changes.
+# Disable this for templates that show the current time with .Now:
+# noCache: true
+
+# Commands that can be used with runCode inside the Markdown file:
+# runners:
+#   go:
+#     command: ["go", "run", "{file}"]
+#     timeout: 10s
+#     extension: .go
+#   python:
+#     command: ["python3"]
+#     extension: .py
+#     cpuLimit: 5s
+#     maxOutput: 65536
+
+# The results of runCode are cached until the code or the runner changes:
+# runCacheDir: .cache/run
+
+# Images included with the image template function are offered in these
+# widths and resized on demand. Resized images are kept in the cache folder:
+# images:
+#   widths: [480, 960, 1920]
+#   quality: 80
+#   cacheDir: .cache/images
+
+# Add a Run button to code blocks included with playCode. By default, only
+# the guide can run code:
+# play:
+#   enabled: true
+#   audience: false
+
+# Override or remove (with an empty value) the security headers sent with
+# every response. {nonce} is replaced by the nonce of the page's scripts:
+# security:
+#   headers:
+#     Content-Security-Policy: "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'unsafe-inline'"
+#     X-Frame-Options: ""
+#   # Pin the Subresource Integrity hashes of remote remark.js and stylesheet
+#   # URLs:
+#   integrity:
+#     "https://remarkjs.com/downloads/remark-latest.min.js": sha384-...
+
+# Serve the presentation through HTTPS using the given certificate and key:
+# tlsCert: cert.pem
+# tlsKey: key.pem
+
+# ... or let remarked generate a self-signed certificate for all local
+# network addresses:
+# tlsSelfSigned: true
 `
 
 // Config is usually the content of a remarked.yml file. Pretty much
@@ -39,12 +115,19 @@ type Config struct {
 	Title        string `yaml:"title"`
 	Stylesheet   string `yaml:"stylesheet"`
 	MarkdownFile string `yaml:"markdownFile"`
-	RemarkJS     string `yaml:"remarkjs"`
+	RemarkJS     string `yaml:"remarkJS"`
 
 	// StaticFolder specifies the folder which should be served under the
 	// /static mountpoint.
 	StaticFolder string `yaml:"staticFolder"`
 
+	// Theme is either the name of a theme or the path to a theme folder.
+	Theme string `yaml:"theme"`
+
+	// Remark contains options passed to remark.create like ratio or
+	// highlightStyle. They take precedence over the options of the theme.
+	Remark map[string]interface{} `yaml:"remark"`
+
 	// The Token should not be read from the config file but should instead be
 	// either generated or explicitly set through the command-line flag.
 	Token string `yaml:"-"`
@@ -53,30 +136,206 @@ type Config struct {
 	// by the HTTP server.
 	FinalStylesheet string `yaml:"-"`
 
+	// The FinalThemeStylesheet is the URL of the theme's stylesheet as it
+	// is being served by the HTTP server.
+	FinalThemeStylesheet string `yaml:"-"`
+
+	// The FinalRemarkJS is the URL of remark.js as it is being served by the
+	// HTTP server. This is either the embedded version, a local file or the
+	// URL set as RemarkJS.
+	FinalRemarkJS string `yaml:"-"`
+
+	// BasePath is the URL prefix the presentation is served under if
+	// multiple presentations are served by the same instance.
+	BasePath string `yaml:"-"`
+
+	// BaseDir is the folder all relative paths inside the presentation are
+	// resolved against. If it is empty, the current working directory is
+	// used.
+	BaseDir string `yaml:"-"`
+
 	MarkdownAsTemplate   bool   `yaml:"markdownAsTemplate"`
 	LeftActionDelimiter  string `yaml:"leftActionDelimiter"`
 	RightActionDelimiter string `yaml:"rightActionDelimiter"`
 
 	TemplateFile string `yaml:"templateFile"`
+
+	// TLSCert and TLSKey point to a certificate and key file that are used
+	// to serve the presentation through HTTPS.
+	TLSCert string `yaml:"tlsCert"`
+	TLSKey  string `yaml:"tlsKey"`
+
+	// If TLSSelfSigned is set, a self-signed certificate for all local
+	// network addresses is generated (or loaded from the cache) and used
+	// instead of TLSCert and TLSKey.
+	TLSSelfSigned bool `yaml:"tlsSelfSigned"`
+
+	// Data is made available as .Data inside Markdown files that are used as
+	// templates.
+	Data map[string]interface{} `yaml:"data"`
+
+	// Env lists the environment variables that are made available as .Env
+	// inside Markdown files that are used as templates.
+	Env []string `yaml:"env"`
+
+	// If NoCache is set, the presentation is rendered for every request
+	// instead of only when one of its files changes (e.g. for templates
+	// that use .Now).
+	NoCache bool `yaml:"noCache"`
+
+	// Runners configures the commands available to runCode, by name.
+	Runners map[string]Runner `yaml:"runners"`
+
+	// RunCacheDir is the folder the results of runCode are cached in.
+	// Default: remarked/run inside the user's cache folder
+	RunCacheDir string `yaml:"runCacheDir"`
+
+	// Play configures running code from within the presentation.
+	Play Play `yaml:"play"`
+
+	// Images configures the resized versions of images inside the static
+	// folder.
+	Images Images `yaml:"images"`
+
+	// Security configures the headers sent by the HTTP server and the
+	// integrity of remote assets.
+	Security Security `yaml:"security"`
+
+	// FinalRemarkJSIntegrity and FinalStylesheetIntegrity are the
+	// Subresource Integrity hashes of remote versions of remark.js and the
+	// stylesheet. They are empty for files served by remarked.
+	FinalRemarkJSIntegrity   string `yaml:"-"`
+	FinalStylesheetIntegrity string `yaml:"-"`
+}
+
+// Runner describes how code is executed by runCode.
+type Runner struct {
+	// Command is the program and its arguments. The placeholder {file} is
+	// replaced by the path of the file to run. If it is missing, the path is
+	// appended.
+	Command []string `yaml:"command"`
+
+	// Timeout after which the program is killed. Default: 10s
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Dir is the working directory. If it is not set, the folder containing
+	// the file is used.
+	Dir string `yaml:"dir"`
+
+	// Extension is used for the temporary file inline snippets are written
+	// to (e.g. ".go").
+	Extension string `yaml:"extension"`
+
+	// CPULimit limits the CPU time the program may use (Unix only).
+	CPULimit time.Duration `yaml:"cpuLimit"`
+
+	// MaxOutput is the number of bytes after which the program is killed.
+	MaxOutput int `yaml:"maxOutput"`
+}
+
+// Play configures the Run button of code blocks included with playCode.
+type Play struct {
+	// Enabled adds the Run button to the guide view.
+	Enabled bool `yaml:"enabled"`
+
+	// If Audience is set, everyone watching the presentation can run code
+	// and not only the guide.
+	Audience bool `yaml:"audience"`
+}
+
+// Images configures the image template function and the resized images
+// served from the static folder.
+type Images struct {
+	// Widths lists the widths images are resized to. Default: 480, 960 and
+	// 1920 pixels
+	Widths []int `yaml:"widths"`
+
+	// Quality of resized JPEG images (1-100). Default: 80
+	Quality int `yaml:"quality"`
+
+	// CacheDir is the folder resized images are stored in. Default:
+	// remarked/images inside the user's cache folder
+	CacheDir string `yaml:"cacheDir"`
+}
+
+// Security configures the response headers and the Subresource Integrity
+// of remote assets.
+type Security struct {
+	// Headers are sent with every response and take precedence over the
+	// default headers. An empty value removes a default header. Inside
+	// Content-Security-Policy, {nonce} is replaced by the nonce that the
+	// scripts of the page carry.
+	Headers map[string]string `yaml:"headers"`
+
+	// Integrity pins the Subresource Integrity hashes (e.g. "sha384-...")
+	// of remote remark.js and stylesheet URLs. Downloads that don't match
+	// are rejected.
+	Integrity map[string]string `yaml:"integrity"`
 }
 
 func (c *Config) String() string {
 	return fmt.Sprintf("<Config Title={%v} Stylesheet={%v} MarkdownFile={%v} RemarkJS={%v} Token={%v} FinalStylesheet={%v}>", c.Title, c.Stylesheet, c.MarkdownFile, c.RemarkJS, c.Token, c.FinalStylesheet)
 }
 
+// ResolvePaths makes all relative file paths inside the configuration
+// relative to the given folder and sets it as BaseDir. URLs are left
+// untouched.
+func (c *Config) ResolvePaths(dir string) {
+	c.BaseDir = dir
+	for _, p := range []*string{&c.MarkdownFile, &c.TemplateFile, &c.Stylesheet, &c.RemarkJS, &c.StaticFolder, &c.TLSCert, &c.TLSKey, &c.Images.CacheDir, &c.RunCacheDir} {
+		if *p == "" || filepath.IsAbs(*p) || isURL(*p) {
+			continue
+		}
+		*p = filepath.Join(dir, *p)
+	}
+	// Only themes that look like a path are folders. Everything else is
+	// the name of a theme.
+	if isThemePath(c.Theme) && !filepath.IsAbs(c.Theme) {
+		c.Theme = filepath.Join(dir, c.Theme)
+	}
+	for name, runner := range c.Runners {
+		if runner.Dir != "" && !filepath.IsAbs(runner.Dir) {
+			runner.Dir = filepath.Join(dir, runner.Dir)
+			c.Runners[name] = runner
+		}
+	}
+}
+
 // LoadFromPath generates a new Config object from the YAML file available
-// through the given path.
+// through the given path and the built-in defaults.
 func LoadFromPath(path string) (*Config, error) {
-	var c Config
-	data, err := ioutil.ReadFile(path)
+	layer, err := FileLayer(path)
 	if err != nil {
 		return nil, err
 	}
-	if c.LeftActionDelimiter == "" {
-		c.LeftActionDelimiter = "{{"
-	}
-	if c.RightActionDelimiter == "" {
-		c.RightActionDelimiter = "}}"
+	c, _, err := Load(Defaults(), layer)
+	return c, err
+}
+
+// Normalize converts all maps inside the given value as they are generated by
+// the YAML decoder into maps with string keys. This makes them usable with
+// everything that expects JSON-like data.
+func Normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[fmt.Sprintf("%v", k)] = Normalize(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = Normalize(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = Normalize(item)
+		}
+		return result
+	default:
+		return value
 	}
-	return &c, yaml.Unmarshal(data, &c)
 }
@@ -0,0 +1,30 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+)
+
+func TestLoadData(t *testing.T) {
+	fp, err := ioutil.TempFile("", "remarked-config")
+	require.NoError(t, err)
+	defer os.Remove(fp.Name())
+	_, err = fp.WriteString("data:\n  event:\n    name: GopherCon\n    tracks: [a, b]\nenv:\n  - USER\n")
+	require.NoError(t, err)
+	require.NoError(t, fp.Close())
+
+	cfg, err := config.LoadFromPath(fp.Name())
+	require.NoError(t, err)
+	expected := map[string]interface{}{
+		"event": map[string]interface{}{
+			"name":   "GopherCon",
+			"tracks": []interface{}{"a", "b"},
+		},
+	}
+	require.Equal(t, expected, cfg.Data)
+	require.Equal(t, []string{"USER"}, cfg.Env)
+}
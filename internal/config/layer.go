@@ -0,0 +1,316 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/zerok/remarked/internal/userdir"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of all environment variables that set
+// configuration values, e.g. REMARKED_TITLE or REMARKED_IMAGES_QUALITY.
+const EnvPrefix = "REMARKED_"
+
+// DefaultSource is the source of the built-in defaults.
+const DefaultSource = "default"
+
+// Layer is a set of configuration values with the same structure as the
+// configuration file together with where they came from.
+type Layer struct {
+	// Source describes where the values came from, e.g. the path of a file
+	// or the name of an environment variable.
+	Source string
+
+	// If Dir is set, relative paths inside the values are resolved against
+	// it.
+	Dir string
+
+	Values map[string]interface{}
+}
+
+// Sources maps the key of every value set by a layer to the source of the
+// layer that set it last. Keys of nested values are joined with dots (e.g.
+// "images.quality").
+type Sources map[string]string
+
+// Defaults returns the layer with the built-in defaults.
+func Defaults() Layer {
+	return Layer{
+		Source: DefaultSource,
+		Values: map[string]interface{}{
+			"leftActionDelimiter":  "{{",
+			"rightActionDelimiter": "}}",
+		},
+	}
+}
+
+// UserFile returns the path of the configuration file that applies to all
+// presentations of the current user. It is empty if there is no
+// configuration folder.
+func UserFile() string {
+	dir, err := userdir.Config()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "remarked", "remarked.yml")
+}
+
+// FileLayer reads the configuration file at the given path.
+func FileLayer(path string) (Layer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Layer{}, err
+	}
+	// Decoding the file on its own first reports errors with the lines of
+	// the file and not those of the merged configuration.
+	if err := decodeStrict(data); err != nil {
+		return Layer{}, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return Layer{}, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	values = Normalize(values).(map[string]interface{})
+	if err := renameDeprecatedKeys(values); err != nil {
+		return Layer{}, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return Layer{Source: path, Values: values}, nil
+}
+
+// EnvLayers returns a layer for every environment variable inside environ
+// (as returned by os.Environ) that starts with EnvPrefix. The name of the
+// variable is the key of the value in upper case with words and nested
+// keys separated by underscores. Lists are separated by commas.
+// Mappings like data cannot be set through the environment.
+//
+// Variables with the prefix that don't match any key are returned as
+// unknown instead of failing as they might just be meant for the Markdown
+// file (see Config.Env) or for something else entirely.
+func EnvLayers(environ []string) ([]Layer, []string, error) {
+	fields := envFields(reflect.TypeOf(Config{}), nil)
+	var layers []Layer
+	var unknown []string
+	for _, variable := range environ {
+		elems := strings.SplitN(variable, "=", 2)
+		if len(elems) != 2 || !strings.HasPrefix(elems[0], EnvPrefix) {
+			continue
+		}
+		field, found := fields[elems[0]]
+		if !found {
+			unknown = append(unknown, elems[0])
+			continue
+		}
+		value, err := parseEnvValue(field.kind, elems[1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid value for %s: %s", elems[0], err.Error())
+		}
+		values := map[string]interface{}{field.path[len(field.path)-1]: value}
+		for idx := len(field.path) - 2; idx >= 0; idx-- {
+			values = map[string]interface{}{field.path[idx]: values}
+		}
+		layers = append(layers, Layer{Source: "$" + elems[0], Values: values})
+	}
+	sort.Slice(layers, func(i, j int) bool {
+		return layers[i].Source < layers[j].Source
+	})
+	sort.Strings(unknown)
+	return layers, unknown, nil
+}
+
+// DescribeUnknownEnv returns a message for an unknown variable returned by
+// EnvLayers that includes the closest known name if there is one.
+func DescribeUnknownEnv(name string) string {
+	fields := envFields(reflect.TypeOf(Config{}), nil)
+	// The common prefix would make all names look alike.
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, strings.TrimPrefix(field, EnvPrefix))
+	}
+	if suggestion := suggest(strings.TrimPrefix(name, EnvPrefix), names); suggestion != "" {
+		return fmt.Sprintf("unknown environment variable %s (did you mean %s%s?)", name, EnvPrefix, suggestion)
+	}
+	return fmt.Sprintf("unknown environment variable %s", name)
+}
+
+type envField struct {
+	path []string
+	kind reflect.Type
+}
+
+// envFields returns all fields of the given struct type that can be set
+// through environment variables by the name of the variable.
+func envFields(t reflect.Type, path []string) map[string]envField {
+	result := make(map[string]envField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		fieldPath := append(append([]string{}, path...), key)
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			for name, f := range envFields(field.Type, fieldPath) {
+				result[name] = f
+			}
+		case reflect.Map:
+			continue
+		default:
+			result[envName(fieldPath)] = envField{path: fieldPath, kind: field.Type}
+		}
+	}
+	return result
+}
+
+// envName returns the name of the environment variable for the given key,
+// e.g. REMARKED_IMAGES_CACHE_DIR for images.cacheDir.
+func envName(path []string) string {
+	words := make([]string, 0, len(path))
+	for _, key := range path {
+		var word []rune
+		for idx, r := range key {
+			if idx > 0 && unicode.IsUpper(r) && unicode.IsLower(rune(key[idx-1])) {
+				word = append(word, '_')
+			}
+			word = append(word, unicode.ToUpper(r))
+		}
+		words = append(words, string(word))
+	}
+	return EnvPrefix + strings.Join(words, "_")
+}
+
+func parseEnvValue(t reflect.Type, value string) (interface{}, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int:
+		return strconv.Atoi(value)
+	case reflect.Slice:
+		items := []interface{}{}
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			parsed, err := parseEnvValue(t.Elem(), item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, parsed)
+		}
+		return items, nil
+	default:
+		return value, nil
+	}
+}
+
+// Load merges the given layers into a configuration. Later layers take
+// precedence over earlier ones. Mappings are merged key by key while all
+// other values (including lists) are replaced as a whole.
+func Load(layers ...Layer) (*Config, Sources, error) {
+	merged := make(map[string]interface{})
+	sources := make(Sources)
+	for _, layer := range layers {
+		values := layer.Values
+		if layer.Dir != "" {
+			var err error
+			if values, err = resolveLayerPaths(values, layer.Dir); err != nil {
+				return nil, nil, fmt.Errorf("%s: %s", layer.Source, err.Error())
+			}
+		}
+		mergeLayer(merged, values, "", layer.Source, sources)
+	}
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, nil, err
+	}
+	for k, v := range c.Data {
+		c.Data[k] = Normalize(v)
+	}
+	for k, v := range c.Remark {
+		c.Remark[k] = Normalize(v)
+	}
+	if err := ValidateRemark(c.Remark); err != nil {
+		return nil, nil, err
+	}
+	if err := c.validateDelimiters(); err != nil {
+		return nil, nil, err
+	}
+	return &c, sources, nil
+}
+
+func mergeLayer(dst map[string]interface{}, src map[string]interface{}, prefix string, source string, sources Sources) {
+	for key, value := range src {
+		nested, isMap := value.(map[string]interface{})
+		existing, existingIsMap := dst[key].(map[string]interface{})
+		if isMap && existingIsMap {
+			mergeLayer(existing, nested, prefix+key+".", source, sources)
+			continue
+		}
+		for k := range sources {
+			if k == prefix+key || strings.HasPrefix(k, prefix+key+".") {
+				delete(sources, k)
+			}
+		}
+		if isMap {
+			copied := make(map[string]interface{}, len(nested))
+			mergeLayer(copied, nested, prefix+key+".", source, sources)
+			dst[key] = copied
+			continue
+		}
+		dst[key] = value
+		sources[prefix+key] = source
+	}
+}
+
+// resolveLayerPaths makes the relative paths inside the values relative to
+// the given folder (see ResolvePaths).
+func resolveLayerPaths(values map[string]interface{}, dir string) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	c.ResolvePaths(dir)
+	if data, err = yaml.Marshal(&c); err != nil {
+		return nil, err
+	}
+	resolved := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &resolved); err != nil {
+		return nil, err
+	}
+	return intersect(Normalize(resolved).(map[string]interface{}), values), nil
+}
+
+// intersect returns the values of a for all keys that are set in b.
+func intersect(a, b map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(b))
+	for key, value := range b {
+		nestedA, okA := a[key].(map[string]interface{})
+		nestedB, okB := value.(map[string]interface{})
+		if okA && okB {
+			result[key] = intersect(nestedA, nestedB)
+			continue
+		}
+		if v, found := a[key]; found {
+			result[key] = v
+		} else {
+			result[key] = value
+		}
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+)
+
+func TestLoadLayers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	userFile := filepath.Join(dir, "user", "remarked.yml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(userFile), 0755))
+	require.NoError(t, ioutil.WriteFile(userFile, []byte("theme: dark\nstylesheet: user.css\nimages:\n  quality: 70\n  widths: [100]\ndata:\n  speaker: Jane\n"), 0644))
+	projectFile := filepath.Join(dir, "remarked.yml")
+	require.NoError(t, ioutil.WriteFile(projectFile, []byte("title: Talk\nimages:\n  widths: [200, 400]\ndata:\n  event: GopherCon\n"), 0644))
+
+	user, err := config.FileLayer(userFile)
+	require.NoError(t, err)
+	user.Dir = filepath.Dir(userFile)
+	project, err := config.FileLayer(projectFile)
+	require.NoError(t, err)
+	env, unknown, err := config.EnvLayers([]string{"HOME=/root", "REMARKED_TITLE=From env", "REMARKED_IMAGES_QUALITY=90", "REMARKED_TLS_SELF_SIGNED=true"})
+	require.NoError(t, err)
+	require.Empty(t, unknown)
+	flag := config.Layer{Source: "--theme", Values: map[string]interface{}{"theme": "light"}}
+
+	cfg, sources, err := config.Load(append([]config.Layer{config.Defaults(), user, project}, append(env, flag)...)...)
+	require.NoError(t, err)
+	require.Equal(t, "From env", cfg.Title)
+	require.Equal(t, "light", cfg.Theme)
+	require.Equal(t, filepath.Join(dir, "user", "user.css"), cfg.Stylesheet)
+	require.Equal(t, 90, cfg.Images.Quality)
+	require.Equal(t, []int{200, 400}, cfg.Images.Widths)
+	require.True(t, cfg.TLSSelfSigned)
+	require.Equal(t, "{{", cfg.LeftActionDelimiter)
+	require.Equal(t, map[string]interface{}{"speaker": "Jane", "event": "GopherCon"}, cfg.Data)
+	require.Equal(t, config.Sources{
+		"leftActionDelimiter":  config.DefaultSource,
+		"rightActionDelimiter": config.DefaultSource,
+		"theme":                "--theme",
+		"stylesheet":           userFile,
+		"images.quality":       "$REMARKED_IMAGES_QUALITY",
+		"images.widths":        projectFile,
+		"data.speaker":         userFile,
+		"data.event":           projectFile,
+		"title":                "$REMARKED_TITLE",
+		"tlsSelfSigned":        "$REMARKED_TLS_SELF_SIGNED",
+	}, sources)
+}
+
+func TestEnvLayers(t *testing.T) {
+	layers, unknown, err := config.EnvLayers([]string{"REMARKED_IMAGES_WIDTHS=480, 960", "REMARKED_ENV=USER,HOME", "REMARKED_MARKDOWN_AS_TEMPLATE=1"})
+	require.NoError(t, err)
+	require.Empty(t, unknown)
+	require.Len(t, layers, 3)
+	require.Equal(t, "$REMARKED_ENV", layers[0].Source)
+	require.Equal(t, map[string]interface{}{"env": []interface{}{"USER", "HOME"}}, layers[0].Values)
+	require.Equal(t, map[string]interface{}{"images": map[string]interface{}{"widths": []interface{}{480, 960}}}, layers[1].Values)
+	require.Equal(t, map[string]interface{}{"markdownAsTemplate": true}, layers[2].Values)
+
+	layers, unknown, err = config.EnvLayers([]string{"REMARKED_UNKNOWN=1", "REMARKED_TITLE=Talk", "REMARKED_TILTE=Talk"})
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+	require.Equal(t, []string{"REMARKED_TILTE", "REMARKED_UNKNOWN"}, unknown)
+	require.Equal(t, "unknown environment variable REMARKED_UNKNOWN", config.DescribeUnknownEnv("REMARKED_UNKNOWN"))
+	require.Equal(t, "unknown environment variable REMARKED_TILTE (did you mean REMARKED_TITLE?)", config.DescribeUnknownEnv("REMARKED_TILTE"))
+	_, unknown, err = config.EnvLayers([]string{"REMARKED_DATA=1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"REMARKED_DATA"}, unknown)
+
+	_, _, err = config.EnvLayers([]string{"REMARKED_IMAGES_QUALITY=high"})
+	require.Error(t, err)
+}
+
+func TestFileLayerErrors(t *testing.T) {
+	fp, err := ioutil.TempFile("", "remarked-config")
+	require.NoError(t, err)
+	defer os.Remove(fp.Name())
+	_, err = fp.WriteString("title: Talk\nimages:\n  quality: high\n")
+	require.NoError(t, err)
+	require.NoError(t, fp.Close())
+
+	_, err = config.FileLayer(fp.Name())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), fp.Name()+": ")
+	require.Contains(t, err.Error(), "line 3")
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// remarkOption describes the type of an option of remark.create. Options
+// of kind "object" list their own options in Fields.
+type remarkOption struct {
+	Kind   string
+	Fields map[string]remarkOption
+}
+
+// remarkOptions are the options of remark.create that can be set in the
+// remark section of the configuration file. Options that expect a function
+// are left out as they cannot be expressed in YAML.
+var remarkOptions = map[string]remarkOption{
+	"ratio":                  {Kind: "ratio"},
+	"source":                 {Kind: "string"},
+	"sourceUrl":              {Kind: "string"},
+	"highlightStyle":         {Kind: "string"},
+	"highlightLanguage":      {Kind: "string"},
+	"highlightLines":         {Kind: "bool"},
+	"highlightSpans":         {Kind: "bool"},
+	"highlightInlineCode":    {Kind: "bool"},
+	"countIncrementalSlides": {Kind: "bool"},
+	"slideNumberFormat":      {Kind: "string"},
+	"includePresenterNotes":  {Kind: "bool"},
+	"excludedClasses":        {Kind: "strings"},
+	"navigation": {Kind: "object", Fields: map[string]remarkOption{
+		"scroll": {Kind: "bool"},
+		"touch":  {Kind: "bool"},
+		"click":  {Kind: "bool"},
+	}},
+	"timer": {Kind: "object", Fields: map[string]remarkOption{
+		"enabled":       {Kind: "bool"},
+		"startOnChange": {Kind: "bool"},
+		"resetable":     {Kind: "bool"},
+	}},
+}
+
+func optionNames(options map[string]remarkOption) []string {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	return names
+}
+
+var ratioPattern = regexp.MustCompile(`^[1-9][0-9]*:[1-9][0-9]*$`)
+
+// ValidateRemark checks that all the given options are known options of
+// remark.create and have the right type. The options are expected to be
+// normalized (see Normalize).
+func ValidateRemark(options map[string]interface{}) error {
+	return validateRemark("", remarkOptions, options)
+}
+
+func validateRemark(prefix string, known map[string]remarkOption, options map[string]interface{}) error {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		option, found := known[key]
+		if !found {
+			return fmt.Errorf("unknown remark option %s%s%s", prefix, key, didYouMean(key, optionNames(known)))
+		}
+		if err := option.check(options[key]); err != nil {
+			return fmt.Errorf("remark option %s%s %s", prefix, key, err.Error())
+		}
+		if option.Kind == "object" {
+			if err := validateRemark(prefix+key+".", option.Fields, options[key].(map[string]interface{})); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// check verifies that the value has the type of the option.
+func (o remarkOption) check(value interface{}) error {
+	valid := false
+	switch o.Kind {
+	case "bool":
+		_, valid = value.(bool)
+	case "string":
+		_, valid = value.(string)
+	case "ratio":
+		s, ok := value.(string)
+		if ok && !ratioPattern.MatchString(s) {
+			return fmt.Errorf("must have the form WIDTH:HEIGHT (e.g. 16:9) but is %s", s)
+		}
+		valid = ok
+	case "strings":
+		items, ok := value.([]interface{})
+		valid = ok
+		for _, item := range items {
+			if _, ok := item.(string); !ok {
+				valid = false
+			}
+		}
+	case "object":
+		_, valid = value.(map[string]interface{})
+	}
+	if !valid {
+		return fmt.Errorf("must be %s", o.description())
+	}
+	return nil
+}
+
+func (o remarkOption) description() string {
+	switch o.Kind {
+	case "bool":
+		return "true or false"
+	case "strings":
+		return "a list of strings"
+	case "object":
+		return "a mapping"
+	default:
+		return "a string"
+	}
+}
+
+// ParseRemarkOption parses an option of remark.create as it is set on the
+// command-line, e.g. "ratio=16:9" or "navigation.scroll=false", into a
+// mapping that can be merged into the remark section of the configuration.
+func ParseRemarkOption(s string) (map[string]interface{}, error) {
+	elems := strings.SplitN(s, "=", 2)
+	if len(elems) != 2 {
+		return nil, fmt.Errorf("remark option %s has to be set as KEY=VALUE", s)
+	}
+	path := strings.Split(elems[0], ".")
+	known := remarkOptions
+	var option remarkOption
+	for idx, key := range path {
+		var found bool
+		option, found = known[key]
+		if !found {
+			return nil, fmt.Errorf("unknown remark option %s%s", strings.Join(path[:idx+1], "."), didYouMean(key, optionNames(known)))
+		}
+		known = option.Fields
+	}
+	var value interface{}
+	switch option.Kind {
+	case "bool":
+		b, err := strconv.ParseBool(elems[1])
+		if err != nil {
+			return nil, fmt.Errorf("remark option %s must be true or false", elems[0])
+		}
+		value = b
+	case "strings":
+		var items []interface{}
+		for _, item := range strings.Split(elems[1], ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value = items
+	case "object":
+		return nil, fmt.Errorf("remark option %s is a mapping. Set its options instead (e.g. %s.%s=...)", elems[0], elems[0], firstKey(option.Fields))
+	default:
+		value = elems[1]
+	}
+	result := map[string]interface{}{path[len(path)-1]: value}
+	for idx := len(path) - 2; idx >= 0; idx-- {
+		result = map[string]interface{}{path[idx]: result}
+	}
+	if err := ValidateRemark(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func firstKey(m map[string]remarkOption) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys[0]
+}
+
+// MergeRemark merges the options of src into dst. Mappings like navigation
+// are merged option by option so that setting one of them keeps the others.
+func MergeRemark(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if nested, ok := value.(map[string]interface{}); ok {
+			if existing, ok := dst[key].(map[string]interface{}); ok {
+				merged := make(map[string]interface{}, len(existing)+len(nested))
+				MergeRemark(merged, existing)
+				MergeRemark(merged, nested)
+				dst[key] = merged
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
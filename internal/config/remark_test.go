@@ -0,0 +1,73 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+)
+
+func TestLoadRemark(t *testing.T) {
+	fp, err := ioutil.TempFile("", "remarked-config")
+	require.NoError(t, err)
+	defer os.Remove(fp.Name())
+	_, err = fp.WriteString("remark:\n  ratio: 16:9\n  navigation:\n    scroll: false\n  excludedClasses: [hidden]\n")
+	require.NoError(t, err)
+	require.NoError(t, fp.Close())
+
+	cfg, err := config.LoadFromPath(fp.Name())
+	require.NoError(t, err)
+	expected := map[string]interface{}{
+		"ratio":           "16:9",
+		"navigation":      map[string]interface{}{"scroll": false},
+		"excludedClasses": []interface{}{"hidden"},
+	}
+	require.Equal(t, expected, cfg.Remark)
+}
+
+func TestValidateRemark(t *testing.T) {
+	require.NoError(t, config.ValidateRemark(nil))
+	require.NoError(t, config.ValidateRemark(map[string]interface{}{"highlightLines": true, "slideNumberFormat": "%current%"}))
+	tests := map[string]map[string]interface{}{
+		"unknown remark option ratios (did you mean ratio?)": {"ratios": "4:3"},
+		"remark option ratio must have the form":             {"ratio": "wide"},
+		"remark option highlightLines must be true":          {"highlightLines": "yes"},
+		"remark option navigation must be a mapping":         {"navigation": true},
+		"unknown remark option navigation.keyboard":          {"navigation": map[string]interface{}{"keyboard": false}},
+		"remark option navigation.click must be true":        {"navigation": map[string]interface{}{"click": 1}},
+		"remark option excludedClasses must be a list":       {"excludedClasses": []interface{}{1}},
+	}
+	for expected, options := range tests {
+		err := config.ValidateRemark(options)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), expected)
+	}
+}
+
+func TestParseRemarkOption(t *testing.T) {
+	option, err := config.ParseRemarkOption("ratio=4:3")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"ratio": "4:3"}, option)
+	option, err = config.ParseRemarkOption("navigation.scroll=false")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"navigation": map[string]interface{}{"scroll": false}}, option)
+	option, err = config.ParseRemarkOption("excludedClasses=hidden, draft")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"excludedClasses": []interface{}{"hidden", "draft"}}, option)
+
+	for _, s := range []string{"ratio", "ratio=wide", "unknown=1", "navigation=false", "navigation.keyboard=false", "highlightLines=maybe"} {
+		_, err := config.ParseRemarkOption(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestMergeRemark(t *testing.T) {
+	dst := map[string]interface{}{"ratio": "4:3", "navigation": map[string]interface{}{"scroll": false}}
+	config.MergeRemark(dst, map[string]interface{}{"ratio": "16:9", "navigation": map[string]interface{}{"click": true}})
+	require.Equal(t, map[string]interface{}{
+		"ratio":      "16:9",
+		"navigation": map[string]interface{}{"scroll": false, "click": true},
+	}, dst)
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// deprecatedKeys maps keys that are still accepted in configuration files to
+// the keys that replace them.
+var deprecatedKeys = map[string]string{
+	"remarkjs": "remarkJS",
+}
+
+// fileConfig is the structure of configuration files. Apart from the
+// settings of Config it accepts the deprecated keys.
+type fileConfig struct {
+	Config   `yaml:",inline"`
+	RemarkJS string `yaml:"remarkjs"`
+}
+
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (.+) not found in struct (\S+)$`)
+
+// decodeStrict decodes the content of a configuration file and rejects
+// unknown keys. Errors for unknown keys suggest the closest valid key.
+func decodeStrict(data []byte) error {
+	err := yaml.UnmarshalStrict(data, &fileConfig{})
+	if err == nil {
+		return nil
+	}
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return err
+	}
+	keys := knownKeys(reflect.TypeOf(Config{}))
+	keys["config.fileConfig"] = keys["config.Config"]
+	problems := make([]string, 0, len(typeErr.Errors))
+	for _, problem := range typeErr.Errors {
+		if m := unknownFieldPattern.FindStringSubmatch(problem); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			problem = fmt.Sprintf("line %d: unknown key %s%s", keyLine(data, line, m[2]), m[2], didYouMean(m[2], keys[m[3]]))
+		}
+		problems = append(problems, problem)
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
+
+// keyLine returns the line of the given key. The YAML decoder only reports
+// the line the mapping with the key starts at, so the key is looked for from
+// there on.
+func keyLine(data []byte, start int, key string) int {
+	pattern := regexp.MustCompile(`^\s*(- )?["']?` + regexp.QuoteMeta(key) + `["']?\s*:`)
+	lines := strings.Split(string(data), "\n")
+	for idx := start - 1; idx >= 0 && idx < len(lines); idx++ {
+		if pattern.MatchString(lines[idx]) {
+			return idx + 1
+		}
+	}
+	return start
+}
+
+// knownKeys returns the YAML keys of the given struct type and all the
+// struct types inside it by the name of the type (e.g. "config.Images").
+func knownKeys(t reflect.Type) map[string][]string {
+	result := make(map[string][]string)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		switch t.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Ptr:
+			walk(t.Elem())
+			return
+		case reflect.Struct:
+		default:
+			return
+		}
+		if _, found := result[t.String()]; found {
+			return
+		}
+		keys := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if key == "" || key == "-" {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		result[t.String()] = keys
+		for i := 0; i < t.NumField(); i++ {
+			walk(t.Field(i).Type)
+		}
+	}
+	walk(t)
+	return result
+}
+
+// renameDeprecatedKeys replaces the deprecated keys inside the values of a
+// configuration file.
+func renameDeprecatedKeys(values map[string]interface{}) error {
+	for old, key := range deprecatedKeys {
+		value, found := values[old]
+		if !found {
+			continue
+		}
+		if _, found := values[key]; found {
+			return fmt.Errorf("%s and %s are both set. Please only use %s", old, key, key)
+		}
+		delete(values, old)
+		values[key] = value
+	}
+	return nil
+}
+
+// didYouMean returns a hint about the candidate closest to the given key if
+// the key is likely a typo of it.
+func didYouMean(key string, candidates []string) string {
+	if suggestion := suggest(key, candidates); suggestion != "" {
+		return fmt.Sprintf(" (did you mean %s?)", suggestion)
+	}
+	return ""
+}
+
+// suggest returns the candidate closest to the given key or an empty string
+// if none is close enough.
+func suggest(key string, candidates []string) string {
+	best := ""
+	bestDistance := len(key)/3 + 1
+	if bestDistance < 2 {
+		bestDistance = 2
+	}
+	bestDistance++
+	sorted := append([]string{}, candidates...)
+	sort.Strings(sorted)
+	for _, candidate := range sorted {
+		distance := levenshtein(strings.ToLower(key), strings.ToLower(candidate))
+		if distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// levenshtein returns the number of single character insertions, deletions
+// and substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// validateDelimiters checks that the action delimiters can be used for
+// templates.
+func (c *Config) validateDelimiters() error {
+	if c.LeftActionDelimiter == "" || c.RightActionDelimiter == "" {
+		return fmt.Errorf("leftActionDelimiter and rightActionDelimiter must not be empty")
+	}
+	if c.LeftActionDelimiter == c.RightActionDelimiter {
+		return fmt.Errorf("leftActionDelimiter and rightActionDelimiter must be different but are both %s", c.LeftActionDelimiter)
+	}
+	return nil
+}
+
+// Validate checks the configuration for problems that would otherwise only
+// show up while the presentation is served: files and folders that don't
+// exist and unusable delimiters. All problems found are returned.
+func (c *Config) Validate() []string {
+	var problems []string
+	if err := c.validateDelimiters(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	check := func(key string, path string, folder bool) {
+		if path == "" || isURL(path) {
+			return
+		}
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: %s does not exist", key, path))
+		case folder && !info.IsDir():
+			problems = append(problems, fmt.Sprintf("%s: %s is not a folder", key, path))
+		case !folder && info.IsDir():
+			problems = append(problems, fmt.Sprintf("%s: %s is a folder", key, path))
+		}
+	}
+	check("markdownFile", c.MarkdownFile, false)
+	check("templateFile", c.TemplateFile, false)
+	check("stylesheet", c.Stylesheet, false)
+	check("remarkJS", c.RemarkJS, false)
+	check("staticFolder", c.StaticFolder, true)
+	if isThemePath(c.Theme) {
+		check("theme", c.Theme, true)
+	}
+	check("tlsCert", c.TLSCert, false)
+	check("tlsKey", c.TLSKey, false)
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		problems = append(problems, "tlsCert and tlsKey have to be set together")
+	}
+	names := make([]string, 0, len(c.Runners))
+	for name := range c.Runners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		runner := c.Runners[name]
+		if len(runner.Command) == 0 {
+			problems = append(problems, fmt.Sprintf("runners.%s.command must not be empty", name))
+		}
+		check("runners."+name+".dir", runner.Dir, true)
+	}
+	return problems
+}
+
+func isURL(path string) bool {
+	return strings.Contains(path, "://") || strings.HasPrefix(path, "//")
+}
+
+func isThemePath(theme string) bool {
+	return strings.ContainsAny(theme, `/\`) || strings.HasPrefix(theme, ".")
+}
@@ -0,0 +1,96 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/config"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	fp, err := ioutil.TempFile("", "remarked-config")
+	require.NoError(t, err)
+	_, err = fp.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, fp.Close())
+	return fp.Name()
+}
+
+func TestFileLayerUnknownKeys(t *testing.T) {
+	path := writeConfig(t, "title: Talk\nmarkdownfile: slides.md\nimages:\n  qualty: 80\nrunners:\n  go:\n    comand: [go, run, '{file}']\n")
+	defer os.Remove(path)
+	_, err := config.FileLayer(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), path+": ")
+	require.Contains(t, err.Error(), "line 2: unknown key markdownfile (did you mean markdownFile?)")
+	require.Contains(t, err.Error(), "line 4: unknown key qualty (did you mean quality?)")
+	require.Contains(t, err.Error(), "line 7: unknown key comand (did you mean command?)")
+
+	path = writeConfig(t, "somethingElse: true\n")
+	defer os.Remove(path)
+	_, err = config.FileLayer(path)
+	require.EqualError(t, err, path+": line 1: unknown key somethingElse")
+}
+
+func TestFileLayerDeprecatedKeys(t *testing.T) {
+	path := writeConfig(t, "remarkjs: remark.js\n")
+	defer os.Remove(path)
+	cfg, err := config.LoadFromPath(path)
+	require.NoError(t, err)
+	require.Equal(t, "remark.js", cfg.RemarkJS)
+
+	path = writeConfig(t, "remarkJS: remark.js\nremarkjs: remark.js\n")
+	defer os.Remove(path)
+	_, err = config.FileLayer(path)
+	require.EqualError(t, err, path+": remarkjs and remarkJS are both set. Please only use remarkJS")
+}
+
+func TestLoadDelimiters(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"must not be empty":    {"leftActionDelimiter": ""},
+		"must be different":    {"leftActionDelimiter": "%%", "rightActionDelimiter": "%%"},
+		"both set empty":       {"leftActionDelimiter": "", "rightActionDelimiter": ""},
+		"right only has to be": {"rightActionDelimiter": ""},
+	}
+	for name, values := range tests {
+		_, _, err := config.Load(config.Defaults(), config.Layer{Source: "test", Values: values})
+		require.Error(t, err, name)
+	}
+	cfg, _, err := config.Load(config.Defaults(), config.Layer{Source: "test", Values: map[string]interface{}{"leftActionDelimiter": "[["}})
+	require.NoError(t, err)
+	require.Equal(t, "[[", cfg.LeftActionDelimiter)
+	require.Equal(t, "}}", cfg.RightActionDelimiter)
+}
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-validate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "slides.md"), []byte("# Hello"), 0600))
+
+	cfg := &config.Config{
+		MarkdownFile:         filepath.Join(dir, "slides.md"),
+		RemarkJS:             "https://remarkjs.com/downloads/remark-latest.min.js",
+		LeftActionDelimiter:  "{{",
+		RightActionDelimiter: "}}",
+	}
+	require.Empty(t, cfg.Validate())
+
+	cfg.Stylesheet = filepath.Join(dir, "missing.css")
+	cfg.StaticFolder = filepath.Join(dir, "slides.md")
+	cfg.Theme = "./theme"
+	cfg.TLSCert = filepath.Join(dir, "slides.md")
+	cfg.Runners = map[string]config.Runner{"go": {}}
+	cfg.RightActionDelimiter = "{{"
+	require.Equal(t, []string{
+		"leftActionDelimiter and rightActionDelimiter must be different but are both {{",
+		"stylesheet: " + cfg.Stylesheet + " does not exist",
+		"staticFolder: " + cfg.StaticFolder + " is not a folder",
+		"theme: ./theme does not exist",
+		"tlsCert and tlsKey have to be set together",
+		"runners.go.command must not be empty",
+	}, cfg.Validate())
+}
@@ -0,0 +1,295 @@
+// Package diagram renders text diagrams as SVG. All elements carry CSS
+// classes (diagram-line, diagram-arrow, diagram-text, ...) so that they can
+// be styled by the presentation's stylesheet. The presentation attributes
+// set on them are only defaults that any CSS rule overrides.
+package diagram
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	cellWidth  = 10
+	cellHeight = 20
+	arrowSize  = 4
+)
+
+// ASCII converts an ASCII-art drawing into an SVG element. Lines are drawn
+// with "-" and "|", corners and junctions with "+" and arrow heads with
+// "<", ">", "^" and "v". Everything else is rendered as text.
+func ASCII(text string) string {
+	g := newGrid(text)
+	var lines segments
+	var arrows bytes.Buffer
+	used := make(map[[2]int]bool)
+	for r := 0; r < g.height; r++ {
+		for c := 0; c < g.width; c++ {
+			x0 := float64(c * cellWidth)
+			x1 := x0 + cellWidth
+			cx := x0 + cellWidth/2
+			y0 := float64(r * cellHeight)
+			y1 := y0 + cellHeight
+			cy := y0 + cellHeight/2
+			switch {
+			case g.isHorizontal(r, c):
+				// Lines starting or ending next to a vertical line touch it.
+				start, end := x0, x1
+				if g.isVertical(r, c-1) {
+					start -= cellWidth / 2
+				}
+				if g.isVertical(r, c+1) {
+					end += cellWidth / 2
+				}
+				lines.horizontal(cy, start, end)
+			case g.isVertical(r, c):
+				lines.vertical(cx, y0, y1)
+			case g.isCorner(r, c):
+				if g.connectsLeft(r, c) {
+					lines.horizontal(cy, x0, cx)
+				}
+				if g.connectsRight(r, c) {
+					lines.horizontal(cy, cx, x1)
+				}
+				if g.connectsUp(r, c) {
+					lines.vertical(cx, y0, cy)
+				}
+				if g.connectsDown(r, c) {
+					lines.vertical(cx, cy, y1)
+				}
+			case g.isArrow(r, c):
+				switch g.at(r, c) {
+				case '>':
+					fmt.Fprintf(&arrows, "M%g %gL%g %gL%g %gZ", x0, cy-arrowSize, x1, cy, x0, cy+arrowSize)
+				case '<':
+					fmt.Fprintf(&arrows, "M%g %gL%g %gL%g %gZ", x1, cy-arrowSize, x0, cy, x1, cy+arrowSize)
+				case '^':
+					fmt.Fprintf(&arrows, "M%g %gL%g %gL%g %gZ", cx-arrowSize, y0+2*arrowSize, cx, y0, cx+arrowSize, y0+2*arrowSize)
+					lines.vertical(cx, y0+2*arrowSize, y1)
+				default:
+					fmt.Fprintf(&arrows, "M%g %gL%g %gL%g %gZ", cx-arrowSize, y1-2*arrowSize, cx, y1, cx+arrowSize, y1-2*arrowSize)
+					lines.vertical(cx, y0, y1-2*arrowSize)
+				}
+			default:
+				continue
+			}
+			used[[2]int{r, c}] = true
+		}
+	}
+
+	var out bytes.Buffer
+	width := g.width * cellWidth
+	height := g.height * cellHeight
+	fmt.Fprintf(&out, `<svg xmlns="http://www.w3.org/2000/svg" class="diagram diagram-ascii" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height, width, height)
+	if d := lines.path(); d != "" {
+		fmt.Fprintf(&out, `<path class="diagram-line" d="%s" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="square"/>`, d)
+	}
+	if arrows.Len() > 0 {
+		fmt.Fprintf(&out, `<path class="diagram-arrow" d="%s" fill="currentColor"/>`, arrows.String())
+	}
+	for r := 0; r < g.height; r++ {
+		for c := 0; c < g.width; c++ {
+			if used[[2]int{r, c}] || g.at(r, c) == ' ' {
+				continue
+			}
+			// Words separated by single spaces are rendered as one text.
+			end := c
+			for end+1 < g.width {
+				if !used[[2]int{r, end + 1}] && g.at(r, end+1) != ' ' {
+					end++
+					continue
+				}
+				if end+2 < g.width && g.at(r, end+1) == ' ' && !used[[2]int{r, end + 2}] && g.at(r, end+2) != ' ' {
+					end += 2
+					continue
+				}
+				break
+			}
+			label := string(g.cells[r][c : end+1])
+			writeText(&out, "diagram-text", float64(c*cellWidth), float64(r*cellHeight+cellHeight/2), "start", label, len(g.cells[r][c:end+1])*cellWidth)
+			c = end
+		}
+	}
+	out.WriteString("</svg>")
+	return out.String()
+}
+
+// writeText writes a text element. If width is greater than zero, the text
+// is stretched to exactly that width so that it lines up with the grid.
+func writeText(out *bytes.Buffer, class string, x, y float64, anchor string, text string, width int) {
+	fmt.Fprintf(out, `<text class="%s" x="%g" y="%g" text-anchor="%s" dominant-baseline="central" fill="currentColor" stroke="none" font-family="monospace" font-size="16"`, class, x, y, anchor)
+	if width > 0 {
+		fmt.Fprintf(out, ` textLength="%d" lengthAdjust="spacingAndGlyphs"`, width)
+	}
+	fmt.Fprintf(out, ">%s</text>", escape(text))
+}
+
+// escape escapes the text for XML. Brackets and backticks are escaped as
+// well as remark.js would otherwise interpret them inside the Markdown.
+func escape(text string) string {
+	var out bytes.Buffer
+	for _, r := range text {
+		switch r {
+		case '&':
+			out.WriteString("&amp;")
+		case '<':
+			out.WriteString("&lt;")
+		case '>':
+			out.WriteString("&gt;")
+		case '"':
+			out.WriteString("&#34;")
+		case '[':
+			out.WriteString("&#91;")
+		case ']':
+			out.WriteString("&#93;")
+		case '`':
+			out.WriteString("&#96;")
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+type grid struct {
+	cells  [][]rune
+	width  int
+	height int
+}
+
+func newGrid(text string) *grid {
+	g := &grid{}
+	text = strings.Trim(strings.Replace(text, "\t", "    ", -1), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		row := []rune(strings.TrimRight(line, " \r"))
+		if len(row) > g.width {
+			g.width = len(row)
+		}
+		g.cells = append(g.cells, row)
+	}
+	for idx, row := range g.cells {
+		for len(row) < g.width {
+			row = append(row, ' ')
+		}
+		g.cells[idx] = row
+	}
+	g.height = len(g.cells)
+	return g
+}
+
+func (g *grid) at(r, c int) rune {
+	if r < 0 || r >= g.height || c < 0 || c >= g.width {
+		return ' '
+	}
+	return g.cells[r][c]
+}
+
+func (g *grid) isHorizontal(r, c int) bool {
+	if g.at(r, c) != '-' {
+		return false
+	}
+	return strings.ContainsRune("-+<>", g.at(r, c-1)) || strings.ContainsRune("-+<>", g.at(r, c+1))
+}
+
+func (g *grid) isVertical(r, c int) bool {
+	return g.at(r, c) == '|'
+}
+
+func (g *grid) isCorner(r, c int) bool {
+	return g.at(r, c) == '+' && (g.connectsLeft(r, c) || g.connectsRight(r, c) || g.connectsUp(r, c) || g.connectsDown(r, c))
+}
+
+func (g *grid) isArrow(r, c int) bool {
+	switch g.at(r, c) {
+	case '>':
+		return strings.ContainsRune("-+", g.at(r, c-1))
+	case '<':
+		return strings.ContainsRune("-+", g.at(r, c+1))
+	case '^':
+		return strings.ContainsRune("|+", g.at(r+1, c))
+	case 'v', 'V':
+		return strings.ContainsRune("|+", g.at(r-1, c)) && !unicode.IsLetter(g.at(r, c-1)) && !unicode.IsLetter(g.at(r, c+1))
+	}
+	return false
+}
+
+func (g *grid) connectsLeft(r, c int) bool {
+	return strings.ContainsRune("-+<", g.at(r, c-1))
+}
+
+func (g *grid) connectsRight(r, c int) bool {
+	return strings.ContainsRune("-+>", g.at(r, c+1))
+}
+
+func (g *grid) connectsUp(r, c int) bool {
+	return strings.ContainsRune("|+^", g.at(r-1, c))
+}
+
+func (g *grid) connectsDown(r, c int) bool {
+	return strings.ContainsRune("|+vV", g.at(r+1, c))
+}
+
+// segments collects horizontal and vertical lines and merges the ones that
+// touch each other.
+type segments struct {
+	h map[float64][][2]float64
+	v map[float64][][2]float64
+}
+
+func (s *segments) horizontal(y, x0, x1 float64) {
+	if s.h == nil {
+		s.h = make(map[float64][][2]float64)
+	}
+	s.h[y] = append(s.h[y], [2]float64{x0, x1})
+}
+
+func (s *segments) vertical(x, y0, y1 float64) {
+	if s.v == nil {
+		s.v = make(map[float64][][2]float64)
+	}
+	s.v[x] = append(s.v[x], [2]float64{y0, y1})
+}
+
+func (s *segments) path() string {
+	var out bytes.Buffer
+	for _, y := range sortedKeys(s.h) {
+		for _, span := range merge(s.h[y]) {
+			fmt.Fprintf(&out, "M%g %gH%g", span[0], y, span[1])
+		}
+	}
+	for _, x := range sortedKeys(s.v) {
+		for _, span := range merge(s.v[x]) {
+			fmt.Fprintf(&out, "M%g %gV%g", x, span[0], span[1])
+		}
+	}
+	return out.String()
+}
+
+func sortedKeys(m map[float64][][2]float64) []float64 {
+	keys := make([]float64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Float64s(keys)
+	return keys
+}
+
+func merge(spans [][2]float64) [][2]float64 {
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i][0] < spans[j][0]
+	})
+	var result [][2]float64
+	for _, span := range spans {
+		if len(result) > 0 && span[0] <= result[len(result)-1][1] {
+			if span[1] > result[len(result)-1][1] {
+				result[len(result)-1][1] = span[1]
+			}
+			continue
+		}
+		result = append(result, span)
+	}
+	return result
+}
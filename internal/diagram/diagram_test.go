@@ -0,0 +1,53 @@
+package diagram_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/diagram"
+)
+
+func TestASCII(t *testing.T) {
+	svg := diagram.ASCII(`
++---+
+| a |--->
++---+
+  |
+  v
+`)
+	require.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" class="diagram diagram-ascii" width="90" height="100"`))
+	require.Contains(t, svg, `<path class="diagram-line" d="M5 10H45M45 30H80M5 50H45M5 10V50M25 60V92M45 10V50"`)
+	require.Contains(t, svg, `<path class="diagram-arrow" d="M80 26L90 30L80 34ZM21 92L25 100L29 92Z"`)
+	require.Contains(t, svg, `>a</text>`)
+
+	// Dashes and plus signs inside of text are not lines.
+	svg = diagram.ASCII("re-use a+b [x] <y>")
+	require.NotContains(t, svg, "diagram-line")
+	require.NotContains(t, svg, "diagram-arrow")
+	require.Contains(t, svg, ">re-use a+b &#91;x&#93; &lt;y&gt;</text>")
+}
+
+func TestSequence(t *testing.T) {
+	svg, err := diagram.Sequence(`
+participant Server
+# Participants can also be introduced by a message.
+Client -> Server: GET /
+Server -> Server: render
+note over Server: cached
+Server --> Client: 200 OK
+`)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" class="diagram diagram-sequence"`))
+	require.Equal(t, 2, strings.Count(svg, `<rect class="diagram-participant"`))
+	require.True(t, strings.Index(svg, ">Server</text>") < strings.Index(svg, ">Client</text>"))
+	require.Contains(t, svg, `<path class="diagram-message diagram-message-dashed"`)
+	require.Contains(t, svg, `<rect class="diagram-note"`)
+	require.Contains(t, svg, ">GET /</text>")
+	require.Contains(t, svg, ">render</text>")
+
+	_, err = diagram.Sequence("A -> B: ok\nA => B")
+	require.EqualError(t, err, `line 2: cannot parse "A => B"`)
+	_, err = diagram.Sequence("")
+	require.Error(t, err)
+}
@@ -0,0 +1,179 @@
+package diagram
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	charWidth       = 9
+	participantGap  = 40
+	participantMin  = 100
+	participantBox  = 36
+	messageHeight   = 40
+	selfLoopWidth   = 30
+	selfLoopHeight  = 20
+	notePadding     = 8
+	diagramMargin   = 10
+	sequenceFontPad = 20
+)
+
+var (
+	participantPattern = regexp.MustCompile(`^participant\s+(.+)$`)
+	notePattern        = regexp.MustCompile(`^note\s+over\s+([^:]+?)\s*:\s*(.*)$`)
+	messagePattern     = regexp.MustCompile(`^(.+?)\s*(-->|->)\s*([^:]+?)\s*(?::\s*(.*))?$`)
+)
+
+type sequenceStep struct {
+	from   int
+	to     int
+	label  string
+	dashed bool
+	note   bool
+}
+
+// Sequence renders a sequence diagram as SVG element. Each line of the text
+// is one of:
+//
+//	participant NAME        declares a participant (optional)
+//	A -> B: message         a message from A to B
+//	A --> B: message        a reply, drawn with a dashed line
+//	note over A: text       a note on A's lifeline
+//
+// Participants are placed in the order they are declared or first used.
+// Empty lines and lines starting with # are ignored.
+func Sequence(text string) (string, error) {
+	var names []string
+	index := make(map[string]int)
+	participant := func(name string) int {
+		if idx, found := index[name]; found {
+			return idx
+		}
+		index[name] = len(names)
+		names = append(names, name)
+		return index[name]
+	}
+	var steps []sequenceStep
+	for lineno, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := participantPattern.FindStringSubmatch(line); m != nil {
+			participant(strings.TrimSpace(m[1]))
+			continue
+		}
+		if m := notePattern.FindStringSubmatch(line); m != nil {
+			idx := participant(m[1])
+			steps = append(steps, sequenceStep{from: idx, to: idx, label: m[2], note: true})
+			continue
+		}
+		if m := messagePattern.FindStringSubmatch(line); m != nil {
+			steps = append(steps, sequenceStep{
+				from:   participant(m[1]),
+				to:     participant(m[3]),
+				label:  m[4],
+				dashed: m[2] == "-->",
+			})
+			continue
+		}
+		return "", fmt.Errorf("line %d: cannot parse %q", lineno+1, line)
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("sequence diagram has no participants")
+	}
+
+	// All columns share the same width so that the widest label fits.
+	column := participantMin
+	widen := func(text string, extra int) {
+		if w := textWidth(text) + extra; w > column {
+			column = w
+		}
+	}
+	for _, name := range names {
+		widen(name, sequenceFontPad)
+	}
+	for _, step := range steps {
+		if step.note {
+			widen(step.label, 2*notePadding+participantGap)
+		} else if step.from == step.to {
+			widen(step.label, 2*selfLoopWidth)
+		} else {
+			distance := step.to - step.from
+			if distance < 0 {
+				distance = -distance
+			}
+			if w := (textWidth(step.label) + sequenceFontPad) / distance; w > column {
+				column = w
+			}
+		}
+	}
+	column += participantGap
+	center := func(idx int) float64 {
+		return float64(diagramMargin + idx*column + column/2)
+	}
+
+	width := 2*diagramMargin + len(names)*column
+	var body bytes.Buffer
+	var arrows bytes.Buffer
+	y := float64(diagramMargin + participantBox)
+	for _, step := range steps {
+		y += messageHeight
+		x1 := center(step.from)
+		x2 := center(step.to)
+		class := "diagram-message"
+		dash := ""
+		if step.dashed {
+			class += " diagram-message-dashed"
+			dash = ` stroke-dasharray="6 4"`
+		}
+		switch {
+		case step.note:
+			w := float64(textWidth(step.label) + 2*notePadding)
+			fmt.Fprintf(&body, `<rect class="diagram-note" x="%g" y="%g" width="%g" height="%d" fill="none" stroke="currentColor"/>`, x1-w/2, y-messageHeight/2-notePadding, w, messageHeight/2+2*notePadding)
+			writeText(&body, "diagram-text diagram-note-text", x1, y-messageHeight/4, "middle", step.label, 0)
+		case step.from == step.to:
+			fmt.Fprintf(&body, `<path class="%s" d="M%g %gH%gV%gH%g" fill="none" stroke="currentColor" stroke-width="2"%s/>`, class, x1, y-selfLoopHeight, x1+selfLoopWidth, y, x1+arrowSize*2, dash)
+			fmt.Fprintf(&arrows, "M%g %gL%g %gL%g %gZ", x1+arrowSize*2, y-arrowSize, x1, y, x1+arrowSize*2, y+arrowSize)
+			writeText(&body, "diagram-text diagram-message-text", x1+selfLoopWidth+notePadding, y-selfLoopHeight/2, "start", step.label, 0)
+			if right := int(x1) + selfLoopWidth + notePadding + textWidth(step.label) + diagramMargin; right > width {
+				width = right
+			}
+			y += selfLoopHeight / 2
+		default:
+			dir := 1.0
+			if x2 < x1 {
+				dir = -1
+			}
+			tip := x2 - dir*2
+			fmt.Fprintf(&body, `<path class="%s" d="M%g %gH%g" fill="none" stroke="currentColor" stroke-width="2"%s/>`, class, x1, y, tip-dir*arrowSize*2, dash)
+			fmt.Fprintf(&arrows, "M%g %gL%g %gL%g %gZ", tip-dir*arrowSize*2, y-arrowSize, tip, y, tip-dir*arrowSize*2, y+arrowSize)
+			writeText(&body, "diagram-text diagram-message-text", (x1+x2)/2, y-notePadding-2, "middle", step.label, 0)
+		}
+	}
+	bottom := y + messageHeight/2
+
+	var out bytes.Buffer
+	height := int(bottom) + diagramMargin
+	fmt.Fprintf(&out, `<svg xmlns="http://www.w3.org/2000/svg" class="diagram diagram-sequence" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height, width, height)
+	for idx, name := range names {
+		x := center(idx)
+		w := float64(column - participantGap)
+		fmt.Fprintf(&out, `<path class="diagram-lifeline" d="M%g %gV%g" fill="none" stroke="currentColor" stroke-dasharray="2 4"/>`, x, float64(diagramMargin+participantBox), bottom)
+		fmt.Fprintf(&out, `<rect class="diagram-participant" x="%g" y="%d" width="%g" height="%d" fill="none" stroke="currentColor" stroke-width="2"/>`, x-w/2, diagramMargin, w, participantBox)
+		writeText(&out, "diagram-text diagram-participant-text", x, float64(diagramMargin+participantBox/2), "middle", name, 0)
+	}
+	out.Write(body.Bytes())
+	if arrows.Len() > 0 {
+		fmt.Fprintf(&out, `<path class="diagram-arrow" d="%s" fill="currentColor"/>`, arrows.String())
+	}
+	out.WriteString("</svg>")
+	return out.String(), nil
+}
+
+func textWidth(text string) int {
+	return utf8.RuneCountInString(text) * charWidth
+}
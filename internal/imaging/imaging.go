@@ -0,0 +1,345 @@
+// Package imaging creates smaller versions of PNG, JPEG and GIF images and
+// keeps them in a cache folder.
+package imaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// DefaultWidths are the widths images are resized to if nothing else is
+// configured.
+var DefaultWidths = []int{480, 960, 1920}
+
+// DefaultQuality is the JPEG quality used if nothing else is configured.
+const DefaultQuality = 80
+
+// Supported checks if the file at the given path is an image that can be
+// resized based on its extension.
+func Supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+	return false
+}
+
+// Info describes an image as it is displayed, i.e. with the orientation
+// stored in JPEG files applied.
+type Info struct {
+	Width  int
+	Height int
+	Format string
+}
+
+// Stat reads the dimensions of the image at the given path without decoding
+// all of it.
+func Stat(path string) (Info, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer fp.Close()
+	cfg, format, err := image.DecodeConfig(fp)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to decode %s: %s", path, err.Error())
+	}
+	info := Info{Width: cfg.Width, Height: cfg.Height, Format: format}
+	if format == "jpeg" {
+		if _, err := fp.Seek(0, io.SeekStart); err != nil {
+			return Info{}, err
+		}
+		if orientation(fp) >= 5 {
+			info.Width, info.Height = info.Height, info.Width
+		}
+	}
+	return info, nil
+}
+
+// Cache stores resized images inside Dir. The name of each file is derived
+// from the path, size and modification time of the original so that changed
+// images are resized again.
+type Cache struct {
+	Dir string
+
+	// Quality of resized JPEG images. If it is 0, DefaultQuality is used.
+	Quality int
+
+	// Concurrency limits how many images are resized at the same time to
+	// bound the memory used for large photos. If it is 0, the number of
+	// CPUs is used.
+	Concurrency int
+
+	lock    sync.Mutex
+	pending map[string]*resizeJob
+	slots   chan struct{}
+}
+
+// resizeJob is a resized version of an image that is being written. Callers
+// asking for the same version wait for it instead of resizing the image
+// again.
+type resizeJob struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+// Resize returns the path of a version of the image at the given path that
+// is at most width pixels wide. Images that are already narrow enough and
+// animated GIFs are returned as they are.
+func (c *Cache) Resize(path string, width int) (string, error) {
+	paths, err := c.resize(path, []int{width})
+	if err != nil {
+		return "", err
+	}
+	return paths[width], nil
+}
+
+// Prefetch resizes the image at the given path to all the given widths in
+// the background, so that the versions are ready once they are requested.
+// Errors are reported once the versions are requested through Resize.
+func (c *Cache) Prefetch(path string, widths []int) {
+	go c.resize(path, widths)
+}
+
+// resize returns the paths of the versions of the image for all the given
+// widths. The original is decoded at most once for all missing versions.
+func (c *Cache) resize(path string, widths []int) (map[int]string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	quality := c.Quality
+	if quality == 0 {
+		quality = DefaultQuality
+	}
+
+	result := make(map[int]string, len(widths))
+	waiting := make(map[int]*resizeJob)
+	owned := make(map[int]*resizeJob)
+	c.lock.Lock()
+	if c.pending == nil {
+		c.pending = make(map[string]*resizeJob)
+	}
+	for _, width := range widths {
+		if info.Width <= width {
+			result[width] = path
+			continue
+		}
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00%d\x00%d", path, stat.Size(), stat.ModTime().UnixNano(), width, quality)
+		target := filepath.Join(c.Dir, hex.EncodeToString(h.Sum(nil))+strings.ToLower(filepath.Ext(path)))
+		if _, err := os.Stat(target); err == nil {
+			result[width] = target
+			continue
+		}
+		if job, found := c.pending[target]; found {
+			waiting[width] = job
+			continue
+		}
+		job := &resizeJob{done: make(chan struct{}), path: target}
+		c.pending[target] = job
+		owned[width] = job
+	}
+	c.lock.Unlock()
+
+	if len(owned) > 0 {
+		err := c.write(path, info, quality, owned)
+		c.lock.Lock()
+		for _, job := range owned {
+			if err != nil {
+				job.err = err
+			}
+			delete(c.pending, job.path)
+		}
+		c.lock.Unlock()
+		for width, job := range owned {
+			close(job.done)
+			result[width] = job.path
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for width, job := range waiting {
+		<-job.done
+		if job.err != nil {
+			return nil, job.err
+		}
+		result[width] = job.path
+	}
+	return result, nil
+}
+
+// write resizes the image to the widths of the given jobs and writes the
+// results to the paths of the jobs. For animated GIFs the path of the jobs
+// is set to the original instead.
+func (c *Cache) write(path string, info Info, quality int, jobs map[int]*resizeJob) error {
+	c.lock.Lock()
+	if c.slots == nil {
+		n := c.Concurrency
+		if n <= 0 {
+			n = runtime.NumCPU()
+		}
+		c.slots = make(chan struct{}, n)
+	}
+	slots := c.slots
+	c.lock.Unlock()
+	slots <- struct{}{}
+	defer func() {
+		<-slots
+	}()
+
+	img, err := decode(path, info.Format)
+	if err != nil {
+		return err
+	}
+	if img == nil {
+		for _, job := range jobs {
+			job.path = path
+		}
+		return nil
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	for width, job := range jobs {
+		height := (info.Height*width + info.Width/2) / info.Width
+		if height < 1 {
+			height = 1
+		}
+		if err := encode(resize(img, width, height), info.Format, quality, c.Dir, job.path); err != nil {
+			return fmt.Errorf("failed to encode %s: %s", path, err.Error())
+		}
+	}
+	return nil
+}
+
+// encode writes the image in the given format to a temporary file inside
+// dir and moves it to target once it is complete.
+func encode(img *image.RGBA, format string, quality int, dir string, target string) error {
+	tmp, err := ioutil.TempFile(dir, "resize")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(tmp, img, &jpeg.Options{Quality: quality})
+	case "gif":
+		err = gif.Encode(tmp, img, nil)
+	default:
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(tmp, img)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// decode reads the image at the given path and applies its orientation. For
+// animated GIFs nil is returned.
+func decode(path string, format string) (*image.RGBA, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	var img image.Image
+	if format == "gif" {
+		anim, err := gif.DecodeAll(fp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %s", path, err.Error())
+		}
+		if len(anim.Image) != 1 {
+			return nil, nil
+		}
+		img = anim.Image[0]
+	} else {
+		img, _, err = image.Decode(fp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %s", path, err.Error())
+		}
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	if format == "jpeg" {
+		if _, err := fp.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		rgba = orient(rgba, orientation(fp))
+	}
+	return rgba, nil
+}
+
+// resize scales the image down to the given size. Every pixel of the result
+// is the average of the pixels it covers in the original.
+func resize(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	span := func(i, n, total int) (int, int) {
+		from := i * total / n
+		to := (i + 1) * total / n
+		if to <= from {
+			to = from + 1
+		}
+		return from, to
+	}
+
+	// Scale the rows first and the columns afterwards.
+	rows := make([]uint32, width*sh*4)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[y*src.Stride:]
+		for x := 0; x < width; x++ {
+			from, to := span(x, width, sw)
+			var sum [4]uint32
+			for i := from; i < to; i++ {
+				for c := 0; c < 4; c++ {
+					sum[c] += uint32(row[i*4+c])
+				}
+			}
+			for c := 0; c < 4; c++ {
+				rows[(y*width+x)*4+c] = sum[c] / uint32(to-from)
+			}
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		from, to := span(y, height, sh)
+		for x := 0; x < width; x++ {
+			var sum [4]uint32
+			for i := from; i < to; i++ {
+				for c := 0; c < 4; c++ {
+					sum[c] += rows[(i*width+x)*4+c]
+				}
+			}
+			for c := 0; c < 4; c++ {
+				dst.Pix[y*dst.Stride+x*4+c] = uint8(sum[c] / uint32(to-from))
+			}
+		}
+	}
+	return dst
+}
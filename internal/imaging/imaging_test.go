@@ -0,0 +1,166 @@
+package imaging_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/imaging"
+)
+
+// halves creates an image whose left half is red and right half is blue.
+func halves(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+	return img
+}
+
+func decodeFile(t *testing.T, path string) image.Image {
+	fp, err := os.Open(path)
+	require.NoError(t, err)
+	defer fp.Close()
+	img, _, err := image.Decode(fp)
+	require.NoError(t, err)
+	return img
+}
+
+func TestResize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-imaging")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cache := &imaging.Cache{Dir: filepath.Join(dir, "cache")}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, halves(100, 50)))
+	original := filepath.Join(dir, "image.png")
+	require.NoError(t, ioutil.WriteFile(original, buf.Bytes(), 0644))
+
+	info, err := imaging.Stat(original)
+	require.NoError(t, err)
+	require.Equal(t, imaging.Info{Width: 100, Height: 50, Format: "png"}, info)
+
+	resized, err := cache.Resize(original, 40)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "cache"), filepath.Dir(resized))
+	require.Equal(t, ".png", filepath.Ext(resized))
+	img := decodeFile(t, resized)
+	require.Equal(t, image.Rect(0, 0, 40, 20), img.Bounds())
+	r, _, b, _ := img.At(5, 10).RGBA()
+	require.True(t, r > b)
+
+	again, err := cache.Resize(original, 40)
+	require.NoError(t, err)
+	require.Equal(t, resized, again)
+
+	// Images are never scaled up.
+	same, err := cache.Resize(original, 200)
+	require.NoError(t, err)
+	require.Equal(t, original, same)
+
+	_, err = cache.Resize(filepath.Join(dir, "missing.png"), 40)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestResizeOrientation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-imaging")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cache := &imaging.Cache{Dir: dir}
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, halves(100, 50), nil))
+	// An APP1 segment with orientation 6 (rotate 90° clockwise).
+	exif := []byte{
+		0xff, 0xe1, 0x00, 0x22,
+		'E', 'x', 'i', 'f', 0, 0,
+		'M', 'M', 0x00, 0x2a, 0x00, 0x00, 0x00, 0x08,
+		0x00, 0x01,
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x06, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	}
+	data := append(append([]byte{0xff, 0xd8}, exif...), buf.Bytes()[2:]...)
+	original := filepath.Join(dir, "photo.jpg")
+	require.NoError(t, ioutil.WriteFile(original, data, 0644))
+
+	info, err := imaging.Stat(original)
+	require.NoError(t, err)
+	require.Equal(t, imaging.Info{Width: 50, Height: 100, Format: "jpeg"}, info)
+
+	resized, err := cache.Resize(original, 20)
+	require.NoError(t, err)
+	img := decodeFile(t, resized)
+	require.Equal(t, image.Rect(0, 0, 20, 40), img.Bounds())
+	// The left half ends up at the top.
+	r, _, b, _ := img.At(10, 5).RGBA()
+	require.True(t, r > b)
+	r, _, b, _ = img.At(10, 35).RGBA()
+	require.True(t, b > r)
+}
+
+func TestResizeAnimatedGIF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-imaging")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cache := &imaging.Cache{Dir: dir}
+
+	palette := color.Palette{color.Black, color.White}
+	frame := image.NewPaletted(image.Rect(0, 0, 100, 50), palette)
+	var buf bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}))
+	original := filepath.Join(dir, "anim.gif")
+	require.NoError(t, ioutil.WriteFile(original, buf.Bytes(), 0644))
+
+	resized, err := cache.Resize(original, 40)
+	require.NoError(t, err)
+	require.Equal(t, original, resized)
+}
+
+func TestResizeConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-imaging")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cache := &imaging.Cache{Dir: filepath.Join(dir, "cache"), Concurrency: 2}
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, halves(400, 200), nil))
+	original := filepath.Join(dir, "photo.jpg")
+	require.NoError(t, ioutil.WriteFile(original, buf.Bytes(), 0644))
+
+	cache.Prefetch(original, []int{100, 200})
+	var wg sync.WaitGroup
+	paths := make([]string, 8)
+	for idx := range paths {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			resized, err := cache.Resize(original, 100*(idx%2+1))
+			require.NoError(t, err)
+			paths[idx] = resized
+		}(idx)
+	}
+	wg.Wait()
+	for idx, resized := range paths {
+		require.Equal(t, paths[idx%2], resized)
+		require.Equal(t, image.Rect(0, 0, 100*(idx%2+1), 50*(idx%2+1)), decodeFile(t, resized).Bounds())
+	}
+	files, err := ioutil.ReadDir(filepath.Join(dir, "cache"))
+	require.NoError(t, err)
+	require.Len(t, files, 2, "Every version should only be written once")
+}
@@ -0,0 +1,92 @@
+// Package integrity computes and verifies Subresource Integrity metadata
+// (e.g. "sha384-...") as it is used in the integrity attribute of script
+// and link elements.
+package integrity
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Compute returns the SHA-384 integrity metadata of the given data.
+func Compute(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Parse checks that the given metadata consists of one or more hashes
+// separated by whitespace, each in the form ALGORITHM-BASE64 with one of
+// the algorithms sha256, sha384 or sha512.
+func Parse(metadata string) error {
+	hashes := strings.Fields(metadata)
+	if len(hashes) == 0 {
+		return fmt.Errorf("integrity metadata is empty")
+	}
+	for _, h := range hashes {
+		if _, _, _, err := parseHash(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify checks that the data matches the given metadata. As in browsers,
+// only the hashes with the strongest algorithm are considered and one of
+// them has to match.
+func Verify(data []byte, metadata string) error {
+	if err := Parse(metadata); err != nil {
+		return err
+	}
+	strongest := 0
+	for _, h := range strings.Fields(metadata) {
+		_, _, strength, _ := parseHash(h)
+		if strength > strongest {
+			strongest = strength
+		}
+	}
+	for _, h := range strings.Fields(metadata) {
+		newHash, expected, strength, _ := parseHash(h)
+		if strength != strongest {
+			continue
+		}
+		hasher := newHash()
+		hasher.Write(data)
+		if subtle.ConstantTimeCompare(hasher.Sum(nil), expected) == 1 {
+			return nil
+		}
+	}
+	return fmt.Errorf("integrity mismatch: expected %s but got %s", metadata, Compute(data))
+}
+
+// parseHash returns the hash function, the expected digest and the
+// strength of the algorithm for a single hash of the metadata.
+func parseHash(h string) (func() hash.Hash, []byte, int, error) {
+	elems := strings.SplitN(h, "-", 2)
+	if len(elems) != 2 {
+		return nil, nil, 0, fmt.Errorf("invalid integrity hash %s", h)
+	}
+	var newHash func() hash.Hash
+	var strength int
+	switch elems[0] {
+	case "sha256":
+		newHash, strength = sha256.New, 1
+	case "sha384":
+		newHash, strength = sha512.New384, 2
+	case "sha512":
+		newHash, strength = sha512.New, 3
+	default:
+		return nil, nil, 0, fmt.Errorf("unsupported integrity algorithm %s", elems[0])
+	}
+	// Options after a "?" are allowed by the specification but ignored.
+	value := strings.SplitN(elems[1], "?", 2)[0]
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(decoded) != newHash().Size() {
+		return nil, nil, 0, fmt.Errorf("invalid integrity hash %s", h)
+	}
+	return newHash, decoded, strength, nil
+}
@@ -0,0 +1,31 @@
+package integrity_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/integrity"
+)
+
+func TestCompute(t *testing.T) {
+	// echo -n "alert('Hello, world.');" | openssl dgst -sha384 -binary | openssl base64 -A
+	require.Equal(t, "sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO", integrity.Compute([]byte("alert('Hello, world.');")))
+}
+
+func TestVerify(t *testing.T) {
+	data := []byte("alert('Hello, world.');")
+	require.NoError(t, integrity.Verify(data, integrity.Compute(data)))
+	require.NoError(t, integrity.Verify(data, "sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng="))
+	require.NoError(t, integrity.Verify(data, "sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng=?ignored"))
+
+	// Only the strongest algorithm counts.
+	err := integrity.Verify(data, "sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng= "+integrity.Compute([]byte("other")))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), integrity.Compute(data))
+	require.NoError(t, integrity.Verify(data, integrity.Compute([]byte("other"))+" "+integrity.Compute(data)))
+
+	for _, metadata := range []string{"", "sha384", "md5-AAAA", "sha384-AAAA", "sha384-!!!"} {
+		require.Error(t, integrity.Verify(data, metadata), metadata)
+		require.Error(t, integrity.Parse(metadata), metadata)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build remarkjs
+// +build remarkjs
+
+package remarkjs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestEmbedded makes sure that builds with the remarkjs tag actually embed
+// remark.js.
+func TestEmbedded(t *testing.T) {
+	require.True(t, Available())
+	require.NotEmpty(t, Version())
+}
@@ -0,0 +1,56 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+func main() {
+	var version string
+	var url string
+	var output string
+	flag.StringVar(&version, "version", "", "Version of remark.js to embed")
+	flag.StringVar(&url, "url", "https://remarkjs.com/downloads/remark-%s.min.js", "URL pattern to download remark.js from")
+	flag.StringVar(&output, "output", "source.go", "Path to the generated Go file")
+	flag.Parse()
+	if version == "" {
+		log.Fatal("Please specify a version using -version")
+	}
+
+	src := fmt.Sprintf(url, version)
+	log.Printf("Downloading %s", src)
+	resp, err := http.Get(src)
+	if err != nil {
+		log.Fatalf("Failed to download %s: %s", src, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("Failed to download %s: unexpected status %s", src, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("Failed to download %s: %s", src, err)
+	}
+	if len(data) == 0 {
+		log.Fatalf("Failed to download %s: empty response", src)
+	}
+
+	fp, err := os.Create(output)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %s", output, err)
+	}
+	defer fp.Close()
+	fmt.Fprintf(fp, "// Code generated by gen.go. DO NOT EDIT.\n\n//go:build remarkjs\n// +build remarkjs\n\npackage remarkjs\n\n")
+	fmt.Fprintf(fp, "// Downloaded from %s (SHA-256: %x)\n\n", src, sha256.Sum256(data))
+	fmt.Fprintf(fp, "const version = %s\n\n", strconv.Quote(version))
+	fmt.Fprintf(fp, "const source = %s\n", strconv.Quote(string(data)))
+}
@@ -0,0 +1,11 @@
+//go:build !remarkjs
+// +build !remarkjs
+
+package remarkjs
+
+// Builds without the remarkjs tag don't embed remark.js. See source.go as
+// generated by gen.go for the other case.
+
+const version = ""
+
+const source = ""
@@ -0,0 +1,28 @@
+// Package remarkjs provides a pinned build of remark.js that is embedded into
+// the remarked binary so that presentations also work without an internet
+// connection.
+//
+// The build is not part of the repository. Run `go generate` (or `make
+// remarkjs`) to download the version pinned below into source.go and build
+// remarked with the remarkjs tag. Builds with the tag fail if source.go is
+// missing. Builds without it fall back to loading remark.js from
+// remarkjs.com.
+package remarkjs
+
+//go:generate go run gen.go -version 0.14.0 -output source.go
+
+// Source returns the embedded remark.js build or an empty string if remarked
+// was built without it.
+func Source() string {
+	return source
+}
+
+// Version returns the version of the embedded remark.js build.
+func Version() string {
+	return version
+}
+
+// Available reports whether remark.js was embedded into the binary.
+func Available() bool {
+	return source != ""
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package runner
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup starts the command in its own process group so that all
+// of its children can be killed together.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// limitCPU wraps the command so that it runs with a CPU time limit.
+func limitCPU(args []string, limit time.Duration) []string {
+	seconds := int(math.Ceil(limit.Seconds()))
+	return append([]string{"sh", "-c", fmt.Sprintf(`ulimit -t %d && exec "$@"`, seconds), "sh"}, args...)
+}
@@ -0,0 +1,20 @@
+package runner
+
+import (
+	"os/exec"
+	"time"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	cmd.Process.Kill()
+}
+
+// limitCPU is not supported on Windows.
+func limitCPU(args []string, limit time.Duration) []string {
+	return args
+}
@@ -0,0 +1,251 @@
+// Package runner executes code snippets of a presentation with a timeout and
+// keeps their output in a cache on disk.
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultTimeout is used if a Spec has no timeout set.
+const DefaultTimeout = 10 * time.Second
+
+// Spec describes a single execution of a program.
+type Spec struct {
+	// Command is the program and its arguments. The placeholder {file} is
+	// replaced by File. If it is missing, File is appended.
+	Command []string
+	File    string
+	Dir     string
+	Timeout time.Duration
+
+	// CPULimit limits the CPU time of the program and every process it
+	// starts. It is only supported on Unix systems.
+	CPULimit time.Duration
+
+	// If MaxOutput is set, the program is killed once it has written more
+	// than the given number of bytes.
+	MaxOutput int
+}
+
+// Args returns the command line with File filled in.
+func (s Spec) Args() []string {
+	args := make([]string, 0, len(s.Command)+1)
+	found := false
+	for _, arg := range s.Command {
+		if strings.Contains(arg, "{file}") {
+			found = true
+			arg = strings.Replace(arg, "{file}", s.File, -1)
+		}
+		args = append(args, arg)
+	}
+	if !found && s.File != "" {
+		args = append(args, s.File)
+	}
+	return args
+}
+
+func (s Spec) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return s.Timeout
+}
+
+// Result describes how a program exited.
+type Result struct {
+	ExitCode  int           `json:"exitCode"`
+	TimedOut  bool          `json:"timedOut"`
+	Truncated bool          `json:"truncated"`
+	Signal    string        `json:"signal"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Failure describes why a run was not successful or returns an empty string
+// if it was.
+func (r *Result) Failure() string {
+	if r.TimedOut {
+		return fmt.Sprintf("timed out after %s", r.Duration.Round(time.Millisecond))
+	}
+	if r.Truncated {
+		return "output limit exceeded"
+	}
+	if r.Signal != "" {
+		return r.Signal
+	}
+	if r.ExitCode != 0 {
+		return fmt.Sprintf("exit status %d", r.ExitCode)
+	}
+	return ""
+}
+
+// Run executes the program described by the spec and writes its stdout and
+// stderr into output. The program is killed once the timeout is reached or
+// done is closed. An error is only returned if the program could not be
+// started.
+func Run(spec Spec, output io.Writer, done <-chan struct{}) (*Result, error) {
+	args := spec.Args()
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command specified")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), spec.timeout())
+	defer cancel()
+	if done != nil {
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	if spec.CPULimit > 0 {
+		args = limitCPU(args, spec.CPULimit)
+	}
+	limited := &limitedWriter{w: output, limit: spec.MaxOutput, exceeded: cancel}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = spec.Dir
+	cmd.Stdout = limited
+	cmd.Stderr = limited
+	setProcessGroup(cmd)
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	result := &Result{}
+	var err error
+	select {
+	case err = <-exited:
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		err = <-exited
+		result.TimedOut = ctx.Err() == context.DeadlineExceeded
+	}
+	result.Truncated = limited.truncated()
+	result.Duration = time.Since(start)
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		result.ExitCode = exitErr.Sys().(syscall.WaitStatus).ExitStatus()
+		if result.ExitCode == -1 {
+			// The program was terminated by a signal.
+			result.Signal = exitErr.String()
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// limitedWriter forwards writes to w until more than limit bytes were
+// written. After that, exceeded is called and all further output is dropped.
+// A limit of 0 disables this.
+type limitedWriter struct {
+	lock     sync.Mutex
+	w        io.Writer
+	limit    int
+	written  int
+	exceeded func()
+	dropped  bool
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.dropped {
+		return len(p), nil
+	}
+	if l.limit > 0 && l.written+len(p) > l.limit {
+		l.w.Write(p[:l.limit-l.written])
+		l.written = l.limit
+		l.dropped = true
+		l.exceeded()
+		return len(p), nil
+	}
+	l.written += len(p)
+	return l.w.Write(p)
+}
+
+func (l *limitedWriter) truncated() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.dropped
+}
+
+// Cache stores the output of runs inside a folder.
+type Cache struct {
+	Dir string
+}
+
+// Entry is a single cached run.
+type Entry struct {
+	Output string `json:"output"`
+	Result Result `json:"result"`
+}
+
+// Key returns the cache key for running the given source with the spec.
+func Key(spec Spec, source []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n%s\n%s\n%s\n%d\n%s\n", spec.Command, spec.Dir, spec.timeout(), spec.CPULimit, spec.MaxOutput, filepath.Base(spec.File))
+	h.Write(source)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Get returns the entry stored for the given key.
+func (c *Cache) Get(key string) (*Entry, bool) {
+	if c == nil || c.Dir == "" {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(c.Dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Put stores the entry for the given key.
+func (c *Cache) Put(key string, entry *Entry) error {
+	if c == nil || c.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(c.Dir, key)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.Dir, key+".json"))
+}
@@ -0,0 +1,80 @@
+package runner_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/runner"
+)
+
+func TestArgs(t *testing.T) {
+	require.Equal(t, []string{"go", "run", "main.go"}, runner.Spec{Command: []string{"go", "run"}, File: "main.go"}.Args())
+	require.Equal(t, []string{"sh", "-c", "cat main.go"}, runner.Spec{Command: []string{"sh", "-c", "cat {file}"}, File: "main.go"}.Args())
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-runner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "script.sh")
+	require.NoError(t, ioutil.WriteFile(script, []byte("echo out\necho err >&2\nexit 3\n"), 0644))
+
+	var output bytes.Buffer
+	result, err := runner.Run(runner.Spec{Command: []string{"sh"}, File: script}, &output, nil)
+	require.NoError(t, err)
+	require.Equal(t, "out\nerr\n", output.String())
+	require.Equal(t, 3, result.ExitCode)
+	require.Equal(t, "exit status 3", result.Failure())
+
+	output.Reset()
+	result, err = runner.Run(runner.Spec{Command: []string{"sh", "-c", "echo start; sleep 5"}, Timeout: 100 * time.Millisecond}, &output, nil)
+	require.NoError(t, err)
+	require.True(t, result.TimedOut)
+	require.Equal(t, "start\n", output.String())
+	require.True(t, result.Duration < 5*time.Second)
+
+	output.Reset()
+	result, err = runner.Run(runner.Spec{Command: []string{"sh", "-c", "while true; do echo 1234567890; done"}, MaxOutput: 25}, &output, nil)
+	require.NoError(t, err)
+	require.True(t, result.Truncated)
+	require.Equal(t, "1234567890\n1234567890\n123", output.String())
+	require.Equal(t, "output limit exceeded", result.Failure())
+
+	output.Reset()
+	result, err = runner.Run(runner.Spec{Command: []string{"sh", "-c", "while true; do :; done"}, CPULimit: time.Second, Timeout: 10 * time.Second}, &output, nil)
+	require.NoError(t, err)
+	require.False(t, result.TimedOut)
+	require.Contains(t, result.Failure(), "signal")
+
+	done := make(chan struct{})
+	close(done)
+	result, err = runner.Run(runner.Spec{Command: []string{"sleep", "5"}}, &output, done)
+	require.NoError(t, err)
+	require.False(t, result.TimedOut)
+	require.True(t, result.Duration < 5*time.Second)
+
+	_, err = runner.Run(runner.Spec{Command: []string{filepath.Join(dir, "missing")}}, &output, nil)
+	require.Error(t, err)
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-runner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cache := &runner.Cache{Dir: filepath.Join(dir, "cache")}
+	spec := runner.Spec{Command: []string{"sh"}, File: "a.sh"}
+	key := runner.Key(spec, []byte("echo 1"))
+	require.NotEqual(t, key, runner.Key(spec, []byte("echo 2")))
+
+	_, found := cache.Get(key)
+	require.False(t, found)
+	require.NoError(t, cache.Put(key, &runner.Entry{Output: "1\n"}))
+	entry, found := cache.Get(key)
+	require.True(t, found)
+	require.Equal(t, "1\n", entry.Output)
+}
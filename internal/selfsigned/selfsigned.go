@@ -0,0 +1,151 @@
+// Package selfsigned generates and caches self-signed TLS certificates so that
+// remarked can be served through HTTPS inside a local network without
+// having to set up a certificate authority first.
+package selfsigned
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const certFileName = "cert.pem"
+const keyFileName = "key.pem"
+
+// Validity is the duration a newly generated certificate is valid for.
+const Validity = time.Hour * 24 * 365
+
+// LoadOrGenerate returns the paths to a certificate and key file inside
+// cacheDir that are valid for all the given hosts. If the cached certificate
+// doesn't cover all hosts or is about to expire, a new one is generated.
+func LoadOrGenerate(cacheDir string, hosts []string) (string, string, error) {
+	certFile := filepath.Join(cacheDir, certFileName)
+	keyFile := filepath.Join(cacheDir, keyFileName)
+	if cert, err := loadCertificate(certFile); err == nil && covers(cert, hosts) {
+		if _, err := os.Stat(keyFile); err == nil {
+			return certFile, keyFile, nil
+		}
+	}
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return "", "", errors.Wrapf(err, "failed to create %s", cacheDir)
+	}
+	if err := generate(certFile, keyFile, hosts); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the certificate stored in the
+// given PEM file formatted as colon-separated hex pairs.
+func Fingerprint(certFile string) (string, error) {
+	cert, err := loadCertificate(certFile)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(cert.Raw)
+	pairs := make([]string, 0, len(sum))
+	for _, b := range sum {
+		pairs = append(pairs, fmt.Sprintf("%02X", b))
+	}
+	return strings.Join(pairs, ":"), nil
+}
+
+// LANHosts returns the hostname of the machine, localhost and all IP
+// addresses of the network interfaces that are up.
+func LANHosts() ([]string, error) {
+	hosts := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hosts = append(hosts, hostname)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list network addresses")
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			hosts = append(hosts, ipnet.IP.String())
+		}
+	}
+	return hosts, nil
+}
+
+func loadCertificate(certFile string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s contains no certificate", certFile)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func covers(cert *x509.Certificate, hosts []string) bool {
+	if time.Now().Add(time.Hour * 24).After(cert.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if err := cert.VerifyHostname(host); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func generate(certFile string, keyFile string, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate key")
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return errors.Wrap(err, "failed to generate serial number")
+	}
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"remarked"}, CommonName: "remarked self-signed"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(Validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return errors.Wrap(err, "failed to create certificate")
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode key")
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600); err != nil {
+		return errors.Wrapf(err, "failed to write %s", keyFile)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %s", certFile)
+	}
+	return nil
+}
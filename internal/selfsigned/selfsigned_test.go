@@ -0,0 +1,38 @@
+package selfsigned_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/selfsigned"
+)
+
+func TestLoadOrGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-selfsigned")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile, err := selfsigned.LoadOrGenerate(dir, []string{"localhost", "127.0.0.1"})
+	require.NoError(t, err)
+	_, err = tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	first, err := selfsigned.Fingerprint(certFile)
+	require.NoError(t, err)
+	require.Len(t, first, 32*3-1)
+
+	// As long as the hosts are covered, the cached certificate is reused.
+	certFile, _, err = selfsigned.LoadOrGenerate(dir, []string{"127.0.0.1"})
+	require.NoError(t, err)
+	second, err := selfsigned.Fingerprint(certFile)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+
+	certFile, _, err = selfsigned.LoadOrGenerate(dir, []string{"192.168.1.10"})
+	require.NoError(t, err)
+	third, err := selfsigned.Fingerprint(certFile)
+	require.NoError(t, err)
+	require.NotEqual(t, first, third, "A new certificate should be generated for new hosts")
+}
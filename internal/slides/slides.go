@@ -0,0 +1,118 @@
+// Package slides splits a remark.js Markdown file into its slides.
+package slides
+
+import (
+	"regexp"
+	"strings"
+)
+
+var propertyPattern = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9_-]*):\s*(.*)$`)
+
+// Property is a key-value pair that is specified at the beginning of a
+// slide, e.g. "name: intro" or "class: center, middle".
+type Property struct {
+	Name  string
+	Value string
+	Line  int
+}
+
+// Slide is a single slide (or a step of an incremental slide) inside a
+// Markdown file. All line numbers are 1-based and refer to the original file.
+type Slide struct {
+	// Line is the line the slide starts on, which is the line after the
+	// separator.
+	Line int
+
+	// Incremental is set if the slide was introduced with the "--"
+	// separator and therefore continues the previous slide.
+	Incremental bool
+
+	Properties []Property
+	Content    string
+	Notes      string
+	NotesLine  int
+}
+
+// Property returns the value of the given property and whether it was set at
+// all.
+func (s *Slide) Property(name string) (string, bool) {
+	for _, p := range s.Properties {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// Parse splits the given Markdown into slides. Separators inside fenced code
+// blocks are ignored just as remark.js does.
+func Parse(markdown string) []Slide {
+	result := make([]Slide, 0, 0)
+	lines := strings.Split(markdown, "\n")
+	current := &Slide{Line: 1}
+	var content []string
+	var notes []string
+	inNotes := false
+	inProperties := true
+	fence := ""
+
+	finish := func() {
+		current.Content = strings.Join(content, "\n")
+		current.Notes = strings.Join(notes, "\n")
+		result = append(result, *current)
+	}
+
+	for idx, line := range lines {
+		lineNumber := idx + 1
+		trimmed := strings.TrimRight(line, " \t\r")
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(trimmed), fence) {
+				fence = ""
+			}
+		} else if f := fenceMarker(trimmed); f != "" {
+			fence = f
+		} else if trimmed == "---" || trimmed == "--" {
+			finish()
+			current = &Slide{Line: lineNumber + 1, Incremental: trimmed == "--"}
+			content = nil
+			notes = nil
+			inNotes = false
+			inProperties = true
+			continue
+		} else if trimmed == "???" && !inNotes {
+			inNotes = true
+			inProperties = false
+			current.NotesLine = lineNumber
+			continue
+		}
+
+		if inProperties && fence == "" {
+			if m := propertyPattern.FindStringSubmatch(trimmed); m != nil {
+				current.Properties = append(current.Properties, Property{Name: m[1], Value: m[2], Line: lineNumber})
+				continue
+			}
+			if trimmed == "" && len(current.Properties) == 0 {
+				content = append(content, line)
+				continue
+			}
+		}
+		inProperties = false
+		if inNotes {
+			notes = append(notes, line)
+		} else {
+			content = append(content, line)
+		}
+	}
+	finish()
+	return result
+}
+
+func fenceMarker(line string) string {
+	line = strings.TrimSpace(line)
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(line, marker) {
+			return marker
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package slides_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/slides"
+)
+
+func TestParse(t *testing.T) {
+	markdown := `name: intro
+class: center, middle
+
+# Hello
+
+???
+
+Some notes
+---
+name: code
+
+` + "```" + `
+---
+` + "```" + `
+--
+More
+`
+	result := slides.Parse(markdown)
+	require.Len(t, result, 3)
+
+	require.Equal(t, 1, result[0].Line)
+	name, found := result[0].Property("name")
+	require.True(t, found)
+	require.Equal(t, "intro", name)
+	require.Equal(t, 2, result[0].Properties[1].Line)
+	require.Equal(t, "\n# Hello\n", result[0].Content)
+	require.Equal(t, 6, result[0].NotesLine)
+	require.Equal(t, "\nSome notes", result[0].Notes)
+
+	require.Equal(t, 10, result[1].Line)
+	require.False(t, result[1].Incremental)
+	require.Equal(t, "\n```\n---\n```", result[1].Content, "Separators inside code blocks should be ignored")
+
+	require.Equal(t, 16, result[2].Line)
+	require.True(t, result[2].Incremental)
+	require.Equal(t, "More\n", result[2].Content)
+}
@@ -0,0 +1,94 @@
+package theme
+
+// builtin contains the themes that are part of remarked. They only rely on
+// fonts that are installed on most systems so that presentations also work
+// offline.
+var builtin = map[string]*Theme{
+	"light": {
+		Description: "Dark text on a white background",
+		Remark:      map[string]interface{}{"highlightStyle": "github"},
+		Stylesheet:  baseStylesheet + lightStylesheet,
+	},
+	"dark": {
+		Description: "Light text on a dark background for dim rooms",
+		Remark:      map[string]interface{}{"highlightStyle": "monokai"},
+		Stylesheet:  baseStylesheet + darkStylesheet,
+	},
+}
+
+const baseStylesheet = `
+body {
+  font-family: "Helvetica Neue", Helvetica, Arial, sans-serif;
+}
+h1, h2, h3 {
+  font-weight: 600;
+  margin-bottom: 0.5em;
+}
+.remark-slide-content {
+  font-size: 28px;
+  padding: 1em 2em;
+}
+.remark-slide-content h1 { font-size: 2.2em; }
+.remark-slide-content h2 { font-size: 1.6em; }
+.remark-slide-content h3 { font-size: 1.2em; }
+.remark-code, .remark-inline-code {
+  font-family: Menlo, Consolas, "DejaVu Sans Mono", monospace;
+}
+.remark-code {
+  font-size: 0.8em;
+  border-radius: 4px;
+}
+.remark-slide-number {
+  font-size: 0.6em;
+  opacity: 0.6;
+}
+img {
+  max-width: 100%;
+  height: auto;
+}
+.diagram {
+  max-width: 100%;
+  height: auto;
+}
+.remarked-run {
+  font: inherit;
+  font-size: 0.6em;
+  padding: 0.2em 1em;
+  border-radius: 4px;
+  border: none;
+  cursor: pointer;
+}
+.remarked-run-output {
+  font-family: Menlo, Consolas, "DejaVu Sans Mono", monospace;
+  font-size: 0.6em;
+  padding: 0.5em;
+  max-height: 10em;
+  overflow: auto;
+}
+`
+
+const lightStylesheet = `
+.remark-slide-content {
+  background: #fff;
+  color: #222;
+}
+a, a:visited { color: #1565c0; }
+.remark-code-line-highlighted { background-color: rgba(255, 235, 59, 0.5); }
+.remarked-run { background: #1565c0; color: #fff; }
+.remarked-run-output { background: #f3f3f3; color: #222; }
+.diagram-line, .diagram-participant, .diagram-message, .diagram-note { stroke: #1565c0; }
+.diagram-arrow { fill: #1565c0; }
+`
+
+const darkStylesheet = `
+.remark-slide-content {
+  background: #1e1f26;
+  color: #e8e8e8;
+}
+a, a:visited { color: #82b1ff; }
+.remark-code-line-highlighted { background-color: rgba(255, 255, 255, 0.15); }
+.remarked-run { background: #82b1ff; color: #1e1f26; }
+.remarked-run-output { background: #000; color: #e8e8e8; }
+.diagram-line, .diagram-participant, .diagram-message, .diagram-note { stroke: #82b1ff; }
+.diagram-arrow { fill: #82b1ff; }
+`
@@ -0,0 +1,172 @@
+// Package theme loads presentation themes. A theme bundles a stylesheet,
+// optionally an output template and assets like fonts, and default options
+// for remark.js. Themes are either built into remarked or loaded from a
+// folder with the following layout:
+//
+//	theme.yml       description and remark options (optional)
+//	style.css       the stylesheet
+//	template.html   the output template (optional)
+//
+// All other files inside the folder are served next to the stylesheet so
+// that it can reference them with relative URLs.
+package theme
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zerok/remarked/internal/config"
+	"github.com/zerok/remarked/internal/userdir"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// MetadataFile contains the description and remark options of a theme
+	// folder.
+	MetadataFile = "theme.yml"
+
+	// StylesheetFile is the stylesheet of a theme folder.
+	StylesheetFile = "style.css"
+
+	// TemplateFile is the output template of a theme folder.
+	TemplateFile = "template.html"
+)
+
+// Theme is a loaded theme.
+type Theme struct {
+	// Name is the name the theme is referred to by. For theme folders this
+	// is the name of the folder.
+	Name string `yaml:"-"`
+
+	Description string                 `yaml:"description"`
+	Remark      map[string]interface{} `yaml:"remark"`
+
+	// Dir is the folder the theme was loaded from. It is empty for built-in
+	// themes.
+	Dir string `yaml:"-"`
+
+	Stylesheet string `yaml:"-"`
+
+	// Template is parsed on top of the default output template unless it
+	// is empty.
+	Template string `yaml:"-"`
+}
+
+// Files returns the paths of the files the theme was loaded from.
+func (t *Theme) Files() []string {
+	if t.Dir == "" {
+		return nil
+	}
+	var files []string
+	for _, name := range []string{MetadataFile, StylesheetFile, TemplateFile} {
+		path := filepath.Join(t.Dir, name)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// UserDir returns the folder themes installed for the current user are
+// looked up in. It is empty if there is no configuration folder.
+func UserDir() string {
+	dir, err := userdir.Config()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "remarked", "themes")
+}
+
+// Load returns the theme with the given name. Names that look like a path
+// are loaded as folder. All other names are looked up inside userDir first
+// and among the built-in themes afterwards.
+func Load(name string, userDir string) (*Theme, error) {
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return LoadDir(name)
+	}
+	if userDir != "" {
+		dir := filepath.Join(userDir, name)
+		if _, err := os.Stat(dir); err == nil {
+			return LoadDir(dir)
+		}
+	}
+	if t, found := builtin[name]; found {
+		clone := *t
+		clone.Name = name
+		return &clone, nil
+	}
+	return nil, fmt.Errorf("unknown theme %s", name)
+}
+
+// LoadDir loads the theme inside the given folder.
+func LoadDir(dir string) (*Theme, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	t := &Theme{}
+	data, err := ioutil.ReadFile(filepath.Join(dir, MetadataFile))
+	if err == nil {
+		if err := yaml.Unmarshal(data, t); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %s", filepath.Join(dir, MetadataFile), err.Error())
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	t.Name = filepath.Base(dir)
+	for k, v := range t.Remark {
+		t.Remark[k] = config.Normalize(v)
+	}
+	if err := config.ValidateRemark(t.Remark); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", filepath.Join(dir, MetadataFile), err.Error())
+	}
+	t.Dir = dir
+	data, err = ioutil.ReadFile(filepath.Join(dir, StylesheetFile))
+	if err != nil {
+		return nil, fmt.Errorf("theme %s has no %s: %s", t.Name, StylesheetFile, err.Error())
+	}
+	t.Stylesheet = string(data)
+	data, err = ioutil.ReadFile(filepath.Join(dir, TemplateFile))
+	if err == nil {
+		t.Template = string(data)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return t, nil
+}
+
+// List returns all themes inside userDir and all built-in themes sorted by
+// name. Themes inside userDir hide built-in themes with the same name.
+// Folders that are not valid themes are skipped.
+func List(userDir string) []*Theme {
+	themes := make(map[string]*Theme)
+	for name := range builtin {
+		t, _ := Load(name, "")
+		themes[name] = t
+	}
+	if userDir != "" {
+		infos, _ := ioutil.ReadDir(userDir)
+		for _, info := range infos {
+			if !info.IsDir() {
+				continue
+			}
+			if t, err := LoadDir(filepath.Join(userDir, info.Name())); err == nil {
+				themes[info.Name()] = t
+			}
+		}
+	}
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]*Theme, 0, len(names))
+	for _, name := range names {
+		result = append(result, themes[name])
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package theme_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/theme"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-theme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	folder := filepath.Join(dir, "corporate")
+	require.NoError(t, os.MkdirAll(folder, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(folder, theme.StylesheetFile), []byte("body {}"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(folder, theme.TemplateFile), []byte("<html></html>"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(folder, theme.MetadataFile), []byte("description: Ours\nremark:\n  ratio: \"16:9\"\n  navigation:\n    scroll: false\n"), 0644))
+
+	th, err := theme.Load("dark", "")
+	require.NoError(t, err)
+	require.Equal(t, "dark", th.Name)
+	require.Equal(t, "", th.Dir)
+	require.Contains(t, th.Stylesheet, ".remark-slide-content")
+	require.Nil(t, th.Files())
+
+	th, err = theme.Load("corporate", dir)
+	require.NoError(t, err)
+	require.Equal(t, "corporate", th.Name)
+	require.Equal(t, folder, th.Dir)
+	require.Equal(t, "Ours", th.Description)
+	require.Equal(t, "body {}", th.Stylesheet)
+	require.Equal(t, "<html></html>", th.Template)
+	require.Equal(t, map[string]interface{}{"ratio": "16:9", "navigation": map[string]interface{}{"scroll": false}}, th.Remark)
+	require.Len(t, th.Files(), 3)
+
+	th, err = theme.Load(folder, "")
+	require.NoError(t, err)
+	require.Equal(t, "corporate", th.Name)
+
+	_, err = theme.Load("missing", dir)
+	require.EqualError(t, err, "unknown theme missing")
+	_, err = theme.Load(dir, "")
+	require.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(folder, theme.MetadataFile), []byte("remark:\n  ratio: wide\n"), 0644))
+	_, err = theme.Load(folder, "")
+	require.Error(t, err)
+}
+
+func TestList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-theme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"dark", "zebra", "broken"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0755))
+		if name != "broken" {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name, theme.StylesheetFile), nil, 0644))
+		}
+	}
+
+	var names, dirs []string
+	for _, th := range theme.List(dir) {
+		names = append(names, th.Name)
+		dirs = append(dirs, th.Dir)
+	}
+	require.Equal(t, []string{"dark", "light", "zebra"}, names)
+	require.Equal(t, []string{filepath.Join(dir, "dark"), "", filepath.Join(dir, "zebra")}, dirs)
+}
@@ -1,10 +1,9 @@
 package token
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"math/big"
 	"net/http"
-	"time"
-	"unicode/utf8"
 )
 
 // Require is a simple HTTP Middleware that checks that the request comes with
@@ -23,16 +22,18 @@ func Require(token string, loginURL string, f http.HandlerFunc) http.HandlerFunc
 }
 
 // Generate produces a simple 6-character random-string that can be used as
-// token.
+// token. Every call returns a new token, even if multiple ones are generated
+// at the same time (e.g. for multiple presentations).
 func Generate() string {
-	rand.Seed(time.Now().Unix())
-	start, _ := utf8.DecodeRuneInString("a")
-	end, _ := utf8.DecodeRuneInString("z")
-	r := int(end) - int(start)
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	max := big.NewInt(int64(len(letters)))
 	result := ""
 	for i := 0; i < 6; i++ {
-		c := rand.Intn(r)
-		result += string(rune(int(start) + c))
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		result += string(letters[n.Int64()])
 	}
 	return result
 }
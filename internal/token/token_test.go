@@ -12,3 +12,8 @@ func TestGenerateToken(t *testing.T) {
 	tkn := token.Generate()
 	assert.Len(t, tkn, 6, "The generated token should have 6 characters")
 }
+
+func TestGenerateUniqueTokens(t *testing.T) {
+	// Tokens generated right after each other must not be the same
+	assert.NotEqual(t, token.Generate(), token.Generate())
+}
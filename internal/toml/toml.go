@@ -0,0 +1,76 @@
+// Package toml decodes TOML data files into the same kind of values as the
+// other data formats: tables become map[string]interface{} and arrays
+// (including arrays of tables) []interface{}. Dates and times are returned
+// as strings in RFC 3339 format.
+package toml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// parseErrorPrefix matches the position the decoder puts in front of the
+// messages of its errors.
+var parseErrorPrefix = regexp.MustCompile(`^toml: line \d+( \(last key .*?\))?: `)
+
+// Unmarshal decodes the given TOML document.
+func Unmarshal(data []byte) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	if _, err := toml.Decode(string(data), &result); err != nil {
+		if perr, ok := err.(toml.ParseError); ok {
+			line := perr.Position.Line
+			if line == 0 {
+				// Errors at the end of the document come without a
+				// position.
+				line = strings.Count(string(data), "\n") + 1
+			}
+			return nil, fmt.Errorf("line %d: %s", line, parseErrorPrefix.ReplaceAllString(perr.Error(), ""))
+		}
+		return nil, err
+	}
+	return normalize(result).(map[string]interface{}), nil
+}
+
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case []map[string]interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			result = append(result, normalize(item))
+		}
+		return result
+	case []interface{}:
+		for idx, item := range v {
+			v[idx] = normalize(item)
+		}
+		return v
+	case time.Time:
+		return formatTime(v)
+	default:
+		return value
+	}
+}
+
+// formatTime formats dates and times without an offset the way they were
+// written. The decoder marks them with special locations.
+func formatTime(t time.Time) string {
+	switch t.Location().String() {
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
@@ -0,0 +1,82 @@
+package toml_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/toml"
+)
+
+func TestUnmarshal(t *testing.T) {
+	input := `# Speakers of the conference
+title = "GopherCon"
+year = 2_018
+rating = 4.5
+public = true
+tags = [ "go", 'tooling',
+  "web" ]
+venue = { city = "Vienna", "zip code" = "1010" }
+
+[[speaker]]
+name = "Jane \"JD\" Doe" # inline comment
+talk.title = "Remarked"
+
+[[speaker]]
+name = 'C:\Users'
+bio = """
+First line
+Second line"""
+
+[meta.sources]
+date = 2018-05-01
+time = 2018-05-01 10:00:00
+`
+	result, err := toml.Unmarshal([]byte(input))
+	require.NoError(t, err)
+	expected := map[string]interface{}{
+		"title":  "GopherCon",
+		"year":   int64(2018),
+		"rating": 4.5,
+		"public": true,
+		"tags":   []interface{}{"go", "tooling", "web"},
+		"venue":  map[string]interface{}{"city": "Vienna", "zip code": "1010"},
+		"speaker": []interface{}{
+			map[string]interface{}{
+				"name": `Jane "JD" Doe`,
+				"talk": map[string]interface{}{"title": "Remarked"},
+			},
+			map[string]interface{}{
+				"name": `C:\Users`,
+				"bio":  "First line\nSecond line",
+			},
+		},
+		"meta": map[string]interface{}{
+			"sources": map[string]interface{}{"date": "2018-05-01", "time": "2018-05-01T10:00:00"},
+		},
+	}
+	require.Equal(t, expected, result)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := map[string]string{
+		"a = 1\na = 2":        "line 2: Key 'a' has already been defined",
+		"a = \"unterminated":  "line 1: unexpected EOF",
+		"\n\nb = [1, 2":       "expected a comma (',') or array terminator",
+		"c = 1 d = 2":         "line 1: expected a top-level item to end with a newline",
+		"[table":              "line 1: expected '.' or ']' to end table name",
+		"e = yes":             "line 1: expected value but found \"yes\"",
+		"f = 1\n[f]\ng = 2\n": "line 2: Key 'f' has already been defined",
+		"x = 017":             "line 1: Invalid integer \"017\": cannot have leading zeroes",
+	}
+	for input, expected := range tests {
+		_, err := toml.Unmarshal([]byte(input))
+		require.Error(t, err, input)
+		require.Contains(t, err.Error(), expected, input)
+	}
+
+	// Documents that end halfway through must not crash the renderer.
+	for _, input := range []string{"[", "[[", "a.", "a = {", "a = {b = 1,", "a = [", "a = \"\"\"", "a = '"} {
+		_, err := toml.Unmarshal([]byte(input))
+		require.Error(t, err, input)
+	}
+}
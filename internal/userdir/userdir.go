@@ -0,0 +1,39 @@
+// Package userdir locates the folders remarked keeps per-user files in.
+package userdir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Config returns the folder for user-specific configuration files:
+// %AppData% on Windows, ~/Library/Application Support on macOS and
+// $XDG_CONFIG_HOME or ~/.config everywhere else. It works like
+// os.UserConfigDir which isn't available before Go 1.13.
+func Config() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		dir := os.Getenv("AppData")
+		if dir == "" {
+			return "", fmt.Errorf("%%AppData%% is not defined")
+		}
+		return dir, nil
+	case "darwin":
+		home := os.Getenv("HOME")
+		if home == "" {
+			return "", fmt.Errorf("$HOME is not defined")
+		}
+		return filepath.Join(home, "Library", "Application Support"), nil
+	default:
+		if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+			return dir, nil
+		}
+		home := os.Getenv("HOME")
+		if home == "" {
+			return "", fmt.Errorf("neither $XDG_CONFIG_HOME nor $HOME are defined")
+		}
+		return filepath.Join(home, ".config"), nil
+	}
+}
@@ -0,0 +1,41 @@
+package userdir_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/userdir"
+)
+
+func TestConfig(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("only for XDG platforms")
+	}
+	xdg, hasXDG := os.LookupEnv("XDG_CONFIG_HOME")
+	home := os.Getenv("HOME")
+	defer func() {
+		os.Setenv("HOME", home)
+		if hasXDG {
+			os.Setenv("XDG_CONFIG_HOME", xdg)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	}()
+
+	os.Setenv("HOME", "/home/user")
+	os.Setenv("XDG_CONFIG_HOME", "/xdg")
+	dir, err := userdir.Config()
+	require.NoError(t, err)
+	require.Equal(t, "/xdg", dir)
+
+	os.Unsetenv("XDG_CONFIG_HOME")
+	dir, err = userdir.Config()
+	require.NoError(t, err)
+	require.Equal(t, "/home/user/.config", dir)
+
+	os.Unsetenv("HOME")
+	_, err = userdir.Config()
+	require.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Watcher periodically checks a set of files and folders for modifications.
+// Polling is not as efficient as relying on the notification mechanisms of
+// the operating system but behaves the same on every platform and doesn't
+// require any additional dependencies.
+type Watcher struct {
+	Interval time.Duration
+	Log      *logrus.Logger
+	lock     sync.Mutex
+	paths    map[string]struct{}
+	state    map[string]fileState
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Add registers the given paths with the watcher. If a path points to a
+// folder, all files within that folder are watched.
+func (w *Watcher) Add(paths ...string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.paths == nil {
+		w.paths = make(map[string]struct{})
+	}
+	if w.state == nil {
+		w.state = make(map[string]fileState)
+	}
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if _, found := w.paths[p]; found {
+			continue
+		}
+		w.paths[p] = struct{}{}
+		snapshot(p, w.state)
+	}
+}
+
+// Check compares the current state of all watched paths with the state
+// recorded during the last check and returns the files that were created,
+// modified or removed in the meantime.
+func (w *Watcher) Check() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	current := make(map[string]fileState)
+	for p := range w.paths {
+		snapshot(p, current)
+	}
+	changed := make([]string, 0, 0)
+	for p, s := range current {
+		if prev, found := w.state[p]; !found || prev != s {
+			changed = append(changed, p)
+		}
+	}
+	for p := range w.state {
+		if _, found := current[p]; !found {
+			changed = append(changed, p)
+		}
+	}
+	w.state = current
+	sort.Strings(changed)
+	return changed
+}
+
+// Run checks the watched paths in the configured interval and calls onChange
+// whenever something has changed. It only returns once the done channel is
+// closed.
+func (w *Watcher) Run(done <-chan struct{}, onChange func(changed []string)) {
+	interval := w.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			changed := w.Check()
+			if len(changed) == 0 {
+				continue
+			}
+			if w.Log != nil {
+				w.Log.Debugf("Detected changes in %v", changed)
+			}
+			onChange(changed)
+		}
+	}
+}
+
+func snapshot(path string, state map[string]fileState) {
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		state[p] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+}
@@ -0,0 +1,36 @@
+package watcher_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/remarked/internal/watcher"
+)
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remarked-watcher")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "slides.md")
+	require.NoError(t, ioutil.WriteFile(file, []byte("# Hello"), 0644))
+
+	w := watcher.Watcher{}
+	w.Add(dir)
+	require.Empty(t, w.Check(), "Nothing should have changed right after adding the folder")
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("# Hello world"), 0644))
+	require.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Minute)))
+	require.Equal(t, []string{file}, w.Check())
+	require.Empty(t, w.Check())
+
+	other := filepath.Join(dir, "other.md")
+	require.NoError(t, ioutil.WriteFile(other, []byte(""), 0644))
+	require.Equal(t, []string{other}, w.Check(), "New files within a watched folder should be reported")
+
+	require.NoError(t, os.Remove(other))
+	require.Equal(t, []string{other}, w.Check(), "Removed files should be reported")
+}